@@ -0,0 +1,39 @@
+package code
+
+import (
+	"gomonkey/token"
+	"sort"
+)
+
+// SourceMap records, for each emitted instruction, the source position of
+// the AST node that produced it.
+type SourceMap struct {
+	offsets   []int
+	positions []token.Position
+}
+
+// Add maps the instruction at offset to pos. Entries at or after offset are
+// dropped first, since the compiler only ever rewinds by truncating.
+func (sm *SourceMap) Add(offset int, pos token.Position) {
+	for n := len(sm.offsets); n > 0 && sm.offsets[n-1] >= offset; n-- {
+		sm.offsets = sm.offsets[:n-1]
+		sm.positions = sm.positions[:n-1]
+	}
+
+	sm.offsets = append(sm.offsets, offset)
+	sm.positions = append(sm.positions, pos)
+}
+
+// Lookup returns the position of the instruction containing offset.
+func (sm *SourceMap) Lookup(offset int) token.Position {
+	if sm == nil {
+		return token.Position{}
+	}
+
+	i := sort.Search(len(sm.offsets), func(i int) bool { return sm.offsets[i] > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+
+	return sm.positions[i-1]
+}
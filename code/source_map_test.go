@@ -0,0 +1,40 @@
+package code
+
+import (
+	"gomonkey/token"
+	"testing"
+)
+
+func TestSourceMap(t *testing.T) {
+	first := token.Position{Line: 1, Column: 1}
+	second := token.Position{Line: 1, Column: 5}
+	third := token.Position{Line: 2, Column: 3}
+
+	sm := &SourceMap{}
+	sm.Add(0, first)
+	sm.Add(3, second)
+	sm.Add(4, third)
+	sm.Add(4, second)
+
+	tests := []struct {
+		offset   int
+		expected token.Position
+	}{
+		{0, first},
+		{2, first},
+		{3, second},
+		{4, second},
+		{10, second},
+	}
+
+	for _, tt := range tests {
+		if got := sm.Lookup(tt.offset); got != tt.expected {
+			t.Errorf("wrong position for offset %d. want=%s, got=%s", tt.offset, tt.expected, got)
+		}
+	}
+
+	var empty *SourceMap
+	if got := empty.Lookup(0); got.IsValid() {
+		t.Errorf("nil source map returned a valid position: %s", got)
+	}
+}
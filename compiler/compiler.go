@@ -0,0 +1,1028 @@
+package compiler
+
+import (
+	"fmt"
+	"gomonkey/ast"
+	"gomonkey/code"
+	"gomonkey/object"
+	"gomonkey/token"
+)
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	sourceMap           *code.SourceMap
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop
+}
+
+// loop tracks the jumps of the innermost loop being compiled: continue
+// jumps straight back to start, break jumps are patched once the end of the
+// loop is known.
+type loop struct {
+	start  int
+	breaks []int
+}
+
+type Compiler struct {
+	symbolTable *SymbolTable
+	constants   []object.Object
+	scopes      []CompilationScope
+	scopeIndex  int
+	pos         token.Position
+
+	// err is the first operand that did not fit its instruction. emit
+	// records it so that its many callers need not check, and Compile
+	// reports it once the node being compiled is done.
+	err error
+}
+
+// Bytecode is a compiled program. NumLocals counts the slots the main
+// program needs for variables declared in its blocks.
+type Bytecode struct {
+	Instructions code.Instructions
+	SourceMap    *code.SourceMap
+	Constants    []object.Object
+	NumLocals    int
+}
+
+var infixOperators = map[token.TokenType]code.Opcode{
+	token.PLUS:        code.OpAdd,
+	token.MINUS:       code.OpSub,
+	token.ASTERISK:    code.OpMul,
+	token.SLASH:       code.OpDiv,
+	token.PERCENT:     code.OpMod,
+	token.POWER:       code.OpPow,
+	token.GT:          code.OpGreaterThan,
+	token.LT:          code.OpLessThan,
+	token.GT_EQ:       code.OpGreaterEqual,
+	token.LT_EQ:       code.OpLessEqual,
+	token.BIT_AND:     code.OpBitAnd,
+	token.BIT_OR:      code.OpBitOr,
+	token.BIT_XOR:     code.OpBitXor,
+	token.SHIFT_LEFT:  code.OpShiftLeft,
+	token.SHIFT_RIGHT: code.OpShiftRight,
+	token.EQ:          code.OpEqual,
+	token.NOT_EQ:      code.OpNotEqual,
+}
+
+// compoundOperators maps each compound assignment operator to the opcode
+// that combines the target's current value with the right-hand side.
+var compoundOperators = map[token.TokenType]code.Opcode{
+	token.PLUS_ASSIGN:     code.OpAdd,
+	token.MINUS_ASSIGN:    code.OpSub,
+	token.ASTERISK_ASSIGN: code.OpMul,
+	token.SLASH_ASSIGN:    code.OpDiv,
+}
+
+var prefixOperators = map[token.TokenType]code.Opcode{
+	token.MINUS:   code.OpMinus,
+	token.BANG:    code.OpBang,
+	token.BIT_NOT: code.OpBitNot,
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		sourceMap:           &code.SourceMap{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, def := range object.Builtins {
+		symbolTable.DefineBuiltin(i, def.Name)
+	}
+
+	return &Compiler{
+		symbolTable: symbolTable,
+		constants:   []object.Object{},
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+
+	return compiler
+}
+
+// Compile emits bytecode for node. Every instruction is attributed to the
+// innermost node being compiled, mirroring how the evaluator positions
+// its errors.
+func (c *Compiler) Compile(node ast.Node) error {
+	previous := c.pos
+	c.pos = node.Pos()
+
+	err := c.compile(node)
+	if err == nil {
+		err = c.err
+	}
+	c.pos = previous
+
+	return err
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		// The top level binds globals directly. Locals of its blocks only
+		// live as long as one run of the program.
+		c.symbolTable.numBlockLocals = 0
+
+		return c.compileStatements(node.Statements)
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		c.enterBlock()
+		err := c.compileStatements(node.Statements)
+		c.leaveBlock()
+
+		return err
+	case *ast.LetStatement:
+		var symbol Symbol
+		fresh := c.isFresh(node.Name.Value)
+
+		// Functions see their own binding so they can recurse; any other
+		// value must still resolve a shadowed name to its previous binding.
+		if fl, ok := node.Value.(*ast.FunctionLiteral); ok {
+			symbol = c.symbolTable.Define(node.Name.Value)
+
+			// A function that assigns to its own name captures the binding,
+			// which must be new before it is captured, as hoisting does.
+			if fresh && assignsTo(fl, node.Name.Value) {
+				c.emit(code.OpNull)
+				c.bindSymbol(symbol, true)
+				fresh = false
+			}
+
+			if err := c.compileFunctionLiteral(fl, node.Name.Value); err != nil {
+				return err
+			}
+		} else {
+			if err := c.Compile(node.Value); err != nil {
+				return err
+			}
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+
+		c.bindSymbol(symbol, fresh)
+
+		// The evaluator yields the bound value for a let statement, so the
+		// VM does the same to keep both engines interchangeable.
+		c.loadSymbol(symbol)
+		c.emit(code.OpPop)
+	case *ast.FunctionStatement:
+		// The declaration was compiled when its block was entered; like a
+		// let statement it still yields the bound value.
+		if err := c.Compile(node.Name); err != nil {
+			return err
+		}
+
+		c.emit(code.OpPop)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BreakStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return &object.Error{Message: "break outside loop", Pos: node.Pos()}
+		}
+
+		loop.breaks = append(loop.breaks, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return &object.Error{Message: "continue outside loop", Pos: node.Pos()}
+		}
+
+		c.emit(code.OpJump, loop.start)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+
+		c.emit(code.OpReturnValue)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return &object.Error{Message: fmt.Sprintf("identifier not found: %s", node.Value), Pos: node.Pos()}
+		}
+
+		c.loadSymbol(symbol)
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.BigIntLiteral:
+		integer := &object.BigInt{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := prefixOperators[node.Token.Type]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+		c.emit(op)
+	case *ast.InfixExpression:
+		if node.Token.Type == token.AND || node.Token.Type == token.OR {
+			return c.compileLogicalExpression(node)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := infixOperators[node.Token.Type]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+		c.emit(op)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.ArrayLiteral:
+		if hasSpread(node.Elements) {
+			return c.compileSpreadList(node.Elements)
+		}
+
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+
+		c.emit(code.OpIndex)
+	case *ast.MemberExpression:
+		if err := c.Compile(node.Object); err != nil {
+			return err
+		}
+
+		member := &object.String{Value: node.Member.Value}
+		c.emit(code.OpConstant, c.addConstant(member))
+		c.emit(code.OpIndex)
+	case *ast.ImportExpression:
+		path := &object.String{Value: node.Path.Value}
+		c.emit(code.OpImport, c.addConstant(path))
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		if hasSpread(node.Arguments) {
+			if err := c.compileSpreadList(node.Arguments); err != nil {
+				return err
+			}
+
+			c.emit(code.OpCallSpread)
+			return nil
+		}
+
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.SpreadExpression:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		c.emit(code.OpSpread)
+	default:
+		return fmt.Errorf("compiler: unsupported node %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	afterConsequencePos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.compileBlockValue(node.Alternative); err != nil {
+			return err
+		}
+	}
+
+	afterAlternativePos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterAlternativePos)
+
+	return nil
+}
+
+// compileBlockValue compiles a block whose value is used, leaving the value
+// of its last statement on the stack. A block with no statement to yield a
+// value, such as an empty one, leaves null, as it does in the evaluator.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+// compileWhileStatement emits the loop and, like the evaluator, leaves null
+// as the value of the statement.
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	loop := &loop{start: len(c.currentInstructions())}
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, loop)
+
+	err := c.Compile(node.Body)
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, loop.start)
+
+	afterLoopPos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterLoopPos)
+
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, afterLoopPos)
+	}
+
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+// compileForStatement keeps an iterator on the stack while the loop runs.
+// OpIterNext pushes the next key and value, or pops the iterator and leaves
+// the loop once it is exhausted; break has to pop the iterator itself.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+
+	c.emit(code.OpGetIter)
+
+	loop := &loop{start: len(c.currentInstructions())}
+	iterNextPos := c.emit(code.OpIterNext, 9999)
+
+	// The loop variables and the body share one block, which gets new
+	// bindings on every iteration.
+	c.enterBlock()
+
+	c.bindSymbol(c.symbolTable.Define(node.Value.Value), true)
+	if node.Key != nil {
+		c.bindSymbol(c.symbolTable.Define(node.Key.Value), true)
+	} else {
+		c.emit(code.OpPop)
+	}
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, loop)
+
+	err := c.compileStatements(node.Body.Statements)
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	c.leaveBlock()
+
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, loop.start)
+
+	breakPos := c.emit(code.OpPop)
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, breakPos)
+	}
+
+	c.changeOperand(iterNextPos, len(c.currentInstructions()))
+
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+// isFresh reports whether defining name now creates a new binding in a
+// block rather than reusing one. Blocks run more than once, so such a
+// binding must not write through a cell captured by an earlier run.
+func (c *Compiler) isFresh(name string) bool {
+	return c.symbolTable.block && !c.symbolTable.definesOwn(name)
+}
+
+// bindSymbol stores the value on top of the stack in a symbol that was
+// just defined, as a new binding if it is fresh.
+func (c *Compiler) bindSymbol(symbol Symbol, fresh bool) {
+	if fresh {
+		c.emit(code.OpDefineLocal, symbol.Index)
+		return
+	}
+
+	c.setSymbol(symbol)
+}
+
+func (c *Compiler) setSymbol(symbol Symbol) {
+	switch symbol.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, symbol.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, symbol.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, symbol.Index)
+	}
+}
+
+// compileLogicalExpression leaves the left operand on the stack and skips
+// the right one when the left operand already decides the result.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	op := code.OpJumpIfFalsyOrPop
+	if node.Token.Type == token.OR {
+		op = code.OpJumpIfTruthyOrPop
+	}
+	jumpPos := c.emit(op, 9999)
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// compileAssignExpression leaves the assigned value on the stack, as the
+// evaluator does. A compound operator reads the target before the
+// right-hand side is evaluated.
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	op, compound := compoundOperators[node.Token.Type]
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if compound {
+			if !ok {
+				return &object.Error{Message: fmt.Sprintf("identifier not found: %s", target.Value), Pos: target.Pos()}
+			}
+			c.loadSymbol(symbol)
+		}
+
+		if !ok || symbol.Scope == BuiltinScope {
+			return &object.Error{Message: fmt.Sprintf("assignment to undeclared variable: %s", target.Value), Pos: target.Pos()}
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+
+		c.setSymbol(symbol)
+		c.loadSymbol(symbol)
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+
+		if compound {
+			c.emit(code.OpDupPair)
+			c.emit(code.OpIndex)
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+
+		c.emit(code.OpSetIndex)
+	default:
+		return &object.Error{Message: fmt.Sprintf("cannot assign to %s", node.Target.String()), Pos: node.Pos()}
+	}
+
+	return nil
+}
+
+// compileSpreadList leaves a single new array holding the values of
+// elements, with each spread element expanded in place.
+func (c *Compiler) compileSpreadList(elements []ast.Expression) error {
+	segments, pending := 0, 0
+
+	for _, el := range elements {
+		if _, ok := el.(*ast.SpreadExpression); !ok {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+			pending++
+			continue
+		}
+
+		if pending > 0 {
+			c.emit(code.OpArray, pending)
+			segments, pending = segments+1, 0
+		}
+
+		if err := c.Compile(el); err != nil {
+			return err
+		}
+		segments++
+	}
+
+	if pending > 0 {
+		c.emit(code.OpArray, pending)
+		segments++
+	}
+
+	if segments > 1 {
+		c.emit(code.OpConcat, segments)
+	}
+
+	return nil
+}
+
+func hasSpread(elements []ast.Expression) bool {
+	for _, el := range elements {
+		if _, ok := el.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+
+	return loops[len(loops)-1]
+}
+
+// hoistFunctions defines the names of all functions declared among
+// statements and then compiles the declarations, so the closures are bound
+// before the first statement runs. A declaration that refers to a later
+// local one captures its slot and sees the value once it is stored.
+//
+// The bodies are compiled before the block's let statements, so names
+// those introduce are defined up front as well. Names that already
+// resolve are left alone to keep `let x = x + 1` reading the outer x.
+func (c *Compiler) hoistFunctions(statements []ast.Statement) error {
+	var declarations []*ast.FunctionStatement
+	var symbols, fresh []Symbol
+
+	define := func(name string) Symbol {
+		if c.isFresh(name) {
+			symbol := c.symbolTable.Define(name)
+			fresh = append(fresh, symbol)
+			return symbol
+		}
+
+		return c.symbolTable.Define(name)
+	}
+
+	for _, s := range statements {
+		if fs, ok := s.(*ast.FunctionStatement); ok {
+			declarations = append(declarations, fs)
+			symbols = append(symbols, define(fs.Name.Value))
+		}
+	}
+
+	if len(declarations) == 0 {
+		return nil
+	}
+
+	for _, s := range statements {
+		if ls, ok := s.(*ast.LetStatement); ok && !c.symbolTable.Defined(ls.Name.Value) {
+			define(ls.Name.Value)
+		}
+	}
+
+	// A block runs again on every loop iteration; its hoisted names need
+	// new bindings first so closures capture this iteration's slots.
+	for _, symbol := range fresh {
+		c.emit(code.OpNull)
+		c.bindSymbol(symbol, true)
+	}
+
+	for i, fs := range declarations {
+		if err := c.compileFunctionLiteral(fs.Function, fs.Name.Value); err != nil {
+			return err
+		}
+
+		c.setSymbol(symbols[i])
+	}
+
+	return nil
+}
+
+// compileStatements compiles the statements of a program, block or
+// function body in the current scope.
+func (c *Compiler) compileStatements(statements []ast.Statement) error {
+	if err := c.hoistFunctions(statements); err != nil {
+		return err
+	}
+
+	for _, s := range statements {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assignsTo reports whether fn, or a function nested in it, assigns to
+// name.
+func assignsTo(fn *ast.FunctionLiteral, name string) bool {
+	found := false
+
+	ast.Modify(fn, func(node ast.Node) ast.Node {
+		if assign, ok := node.(*ast.AssignExpression); ok {
+			if ident, ok := assign.Target.(*ast.Identifier); ok && ident.Value == name {
+				found = true
+			}
+		}
+
+		return node
+	})
+
+	return found
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+
+	// A function that assigns to its own name rebinds the variable it is
+	// stored in, as in the evaluator, so the name resolves to that
+	// variable rather than to the running closure.
+	if name != "" && !assignsTo(node, name) {
+		c.symbolTable.DefineFunctionName(name)
+	}
+
+	params := make([]Symbol, len(node.Parameters))
+	for i, p := range node.Parameters {
+		params[i] = c.symbolTable.Define(p.Value)
+	}
+
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+
+	// Each default is evaluated on entry unless the caller passed that
+	// argument, so it sees the parameters before it.
+	numDefaults := 0
+	for i, def := range node.Defaults {
+		if def == nil {
+			continue
+		}
+		numDefaults++
+
+		jumpPos := c.emit(code.OpJumpIfArg, i, 9999)
+
+		if err := c.Compile(def); err != nil {
+			return err
+		}
+		c.emit(code.OpSetLocal, params[i].Index)
+
+		c.replaceInstruction(jumpPos, code.Make(code.OpJumpIfArg, i, len(c.currentInstructions())))
+	}
+
+	if err := c.compileStatements(node.Body.Statements); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	sourceMap := c.scopes[c.scopeIndex].sourceMap
+	instructions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		SourceMap:     sourceMap,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumDefaults:   numDefaults,
+		Variadic:      node.Rest != nil,
+		Name:          node.Name,
+		Literal:       node,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		SourceMap:    c.scopes[c.scopeIndex].sourceMap,
+		Constants:    c.constants,
+		NumLocals:    c.symbolTable.numBlockLocals,
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.scopes[c.scopeIndex].sourceMap.Add(pos, c.pos)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+// operandLimits names what each operand counts, for the error reported
+// when a program needs more of it than the operand can hold.
+var operandLimits = map[code.Opcode][]string{
+	code.OpConstant:     {"constants"},
+	code.OpImport:       {"constants"},
+	code.OpGetGlobal:    {"global variables"},
+	code.OpSetGlobal:    {"global variables"},
+	code.OpGetLocal:     {"local variables"},
+	code.OpSetLocal:     {"local variables"},
+	code.OpDefineLocal:  {"local variables"},
+	code.OpCaptureLocal: {"local variables"},
+	code.OpGetFree:      {"free variables"},
+	code.OpSetFree:      {"free variables"},
+	code.OpCaptureFree:  {"free variables"},
+	code.OpClosure:      {"constants", "free variables"},
+	code.OpCall:         {"arguments"},
+	code.OpJumpIfArg:    {"parameters", "instructions"},
+	code.OpArray:        {"elements"},
+	code.OpHash:         {"elements"},
+	code.OpConcat:       {"elements"},
+}
+
+// checkOperands records an error if an operand of op does not fit in its
+// width, as code.Make would silently truncate it.
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	if c.err != nil {
+		return
+	}
+
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		return
+	}
+
+	for i, operand := range operands {
+		if operand < 1<<(8*def.OperandWidths[i]) {
+			continue
+		}
+
+		what := "instructions"
+		if names, ok := operandLimits[op]; ok {
+			what = names[i]
+		}
+
+		c.err = &object.Error{Message: "too many " + what, Pos: c.pos}
+		return
+	}
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.checkOperands(op, []int{operand})
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		sourceMap:           &code.SourceMap{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) enterBlock() {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlock() {
+	c.symbolTable = c.symbolTable.Outer
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+// captureSymbol pushes s for OpClosure. Locals and free variables are
+// passed as cells rather than values so that the closure and its enclosing
+// function keep sharing the variable.
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
@@ -0,0 +1,167 @@
+package compiler
+
+import "sort"
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+
+	FreeSymbols []Symbol
+
+	// block is set for the scope of a block statement. Its names live in
+	// the frame of the enclosing function, or of the main program when
+	// the block is at the top level; there numBlockLocals counts them.
+	block          bool
+	numBlockLocals int
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+
+	return s
+}
+
+// NewBlockSymbolTable returns the table for a block nested in outer. It
+// shadows outer's names but allocates its slots in the same frame.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+
+	return s
+}
+
+// Define binds name in this table. Redefining a name that is already a
+// global or local of this table reuses its slot, so that `let` overwrites a
+// binding the way it does in the evaluator's environments: functions that
+// captured the name see the new value, as does code compiled before the
+// redefinition, such as a loop condition.
+func (s *SymbolTable) Define(name string) Symbol {
+	if s.definesOwn(name) {
+		return s.store[name]
+	}
+
+	frame := s
+	for frame.block {
+		frame = frame.Outer
+	}
+
+	symbol := Symbol{Name: name, Scope: LocalScope}
+
+	switch {
+	case frame.Outer == nil && s.block:
+		symbol.Index = frame.numBlockLocals
+		frame.numBlockLocals++
+	case frame.Outer == nil:
+		symbol.Scope = GlobalScope
+		fallthrough
+	default:
+		symbol.Index = frame.numDefinitions
+		frame.numDefinitions++
+	}
+
+	s.store[name] = symbol
+
+	return symbol
+}
+
+// definesOwn reports whether name is already a global or local of this
+// table itself, so that defining it again reuses the binding.
+func (s *SymbolTable) definesOwn(name string) bool {
+	symbol, ok := s.store[name]
+
+	return ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope)
+}
+
+// Globals returns the global symbols defined in s, ordered by index.
+func (s *SymbolTable) Globals() []Symbol {
+	globals := []Symbol{}
+	for _, symbol := range s.store {
+		if symbol.Scope == GlobalScope {
+			globals = append(globals, symbol)
+		}
+	}
+
+	sort.Slice(globals, func(i, j int) bool { return globals[i].Index < globals[j].Index })
+
+	return globals
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+
+	return symbol
+}
+
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.Resolve(name)
+		if !ok {
+			return obj, ok
+		}
+
+		if s.block || obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+			return obj, ok
+		}
+
+		free := s.defineFree(obj)
+		return free, true
+	}
+
+	return obj, ok
+}
+
+// Defined reports whether name resolves in this table or an enclosing
+// one. Unlike Resolve it does not turn the name into a free variable.
+func (s *SymbolTable) Defined(name string) bool {
+	for table := s; table != nil; table = table.Outer {
+		if _, ok := table.store[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+
+	return symbol
+}
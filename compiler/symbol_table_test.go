@@ -0,0 +1,206 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+
+	if c := local.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+
+	if d := local.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+}
+
+func TestRedefineReusesSlot(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	global.Define("b")
+
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefined global got a new slot. want=%+v, got=%+v", a, again)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	shadow := local.Define("a")
+
+	expected := Symbol{Name: "a", Scope: LocalScope, Index: 0}
+	if shadow != expected {
+		t.Errorf("local did not shadow global. want=%+v, got=%+v", expected, shadow)
+	}
+}
+
+func TestBlockSymbolTables(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	block := NewBlockSymbolTable(global)
+	nested := NewBlockSymbolTable(block)
+
+	expected := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+	}{
+		{block, "a", Symbol{Name: "a", Scope: LocalScope, Index: 0}},
+		{block, "b", Symbol{Name: "b", Scope: LocalScope, Index: 1}},
+		{nested, "c", Symbol{Name: "c", Scope: LocalScope, Index: 2}},
+	}
+
+	for _, e := range expected {
+		if result := e.table.Define(e.name); result != e.expected {
+			t.Errorf("expected %s to be %+v, got=%+v", e.name, e.expected, result)
+		}
+	}
+
+	if global.numDefinitions != 1 || global.numBlockLocals != 3 {
+		t.Errorf("wrong slot counts. globals=%d, block locals=%d", global.numDefinitions, global.numBlockLocals)
+	}
+
+	if result, _ := nested.Resolve("a"); result != (Symbol{Name: "a", Scope: LocalScope, Index: 0}) {
+		t.Errorf("nested block does not see the shadowing local. got=%+v", result)
+	}
+
+	fn := NewEnclosedSymbolTable(nested)
+	fn.Define("x")
+	fnBlock := NewBlockSymbolTable(fn)
+
+	if result := fnBlock.Define("y"); result != (Symbol{Name: "y", Scope: LocalScope, Index: 1}) {
+		t.Errorf("block in function got the wrong slot. got=%+v", result)
+	}
+
+	if result, _ := fnBlock.Resolve("c"); result != (Symbol{Name: "c", Scope: FreeScope, Index: 0}) {
+		t.Errorf("block local of the enclosing frame is not free. got=%+v", result)
+	}
+
+	if len(fnBlock.FreeSymbols) != 0 || len(fn.FreeSymbols) != 1 {
+		t.Errorf("free symbol recorded in the wrong table")
+	}
+}
+
+func TestDefinedDoesNotCreateFreeSymbols(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("b")
+
+	inner := NewEnclosedSymbolTable(outer)
+
+	for _, name := range []string{"a", "b"} {
+		if !inner.Defined(name) {
+			t.Errorf("%s not defined", name)
+		}
+	}
+
+	if inner.Defined("c") {
+		t.Errorf("c defined but was never declared")
+	}
+
+	if len(inner.FreeSymbols) != 0 {
+		t.Errorf("Defined created free symbols: %+v", inner.FreeSymbols)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("e")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "len", Scope: BuiltinScope, Index: 0},
+		{Name: "c", Scope: FreeScope, Index: 0},
+		{Name: "e", Scope: LocalScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := secondLocal.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 1 || secondLocal.FreeSymbols[0] != (Symbol{Name: "c", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong free symbols. got=%+v", secondLocal.FreeSymbols)
+	}
+
+	if _, ok := secondLocal.Resolve("z"); ok {
+		t.Errorf("name z resolved, but was expected not to")
+	}
+}
+
+func TestDefineAndResolveFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineFunctionName("a")
+
+	expected := Symbol{Name: "a", Scope: FunctionScope, Index: 0}
+
+	result, ok := global.Resolve(expected.Name)
+	if !ok {
+		t.Fatalf("function name %s not resolvable", expected.Name)
+	}
+
+	if result != expected {
+		t.Errorf("expected %s to resolve to %+v, got=%+v", expected.Name, expected, result)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "len")
+	global.Define("a")
+	global.Define("b")
+
+	block := NewBlockSymbolTable(global)
+	block.Define("c")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("d")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "b", Scope: GlobalScope, Index: 1},
+	}
+
+	globals := global.Globals()
+	if len(globals) != len(expected) {
+		t.Fatalf("wrong number of globals. want=%d, got=%d (%+v)", len(expected), len(globals), globals)
+	}
+
+	for i, sym := range expected {
+		if globals[i] != sym {
+			t.Errorf("global %d wrong. want=%+v, got=%+v", i, sym, globals[i])
+		}
+	}
+}
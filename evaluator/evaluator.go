@@ -1,26 +1,83 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 	"gomonkey/ast"
 	"gomonkey/object"
 	"gomonkey/token"
+	"math"
+	"math/big"
+	"time"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = object.True
+	FALSE    = object.False
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// Limits bound an evaluation started with EvalContext. Zero values mean no
+// limit. Without MaxDepth, runaway recursion overflows the Go stack.
+type Limits struct {
+	// MaxSteps caps the number of nodes evaluated.
+	MaxSteps int64
+	// MaxDepth caps the number of nested function calls.
+	MaxDepth int
+	// Timeout caps the wall-clock time, on top of any deadline of ctx.
+	Timeout time.Duration
+}
+
+// EvalContext evaluates node in env like Eval, but stops with an
+// *object.Error of kind object.LimitError once a limit is exceeded or ctx
+// is done. The limits apply only for the duration of this call.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits Limits) object.Object {
+	return withLimits(ctx, env, limits, func() object.Object {
+		return Eval(node, env)
+	})
+}
+
+// withLimits runs f with a budget for limits installed in env.
+func withLimits(ctx context.Context, env *object.Environment, limits Limits, f func() object.Object) object.Object {
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	previous := env.Budget()
+	env.SetBudget(object.NewBudget(ctx, limits.MaxSteps, limits.MaxDepth))
+	defer env.SetBudget(previous)
+
+	return f()
+}
+
+// Eval evaluates node in env. Errors raised while evaluating node are
+// tagged with the position of the innermost node that produced them.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if err := env.Budget().Step(); err != nil {
+		return err
+	}
+
+	result := eval(node, env)
+
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		err.Pos = node.Pos()
+	}
+
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, object.NewEnclosedEnvironment(env))
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 
@@ -29,6 +86,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 
 		return &object.ReturnValue{Value: val}
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
@@ -37,9 +102,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		env.Set(node.Name.Value, val)
 
+		return evalIdentifier(node.Name, env)
+	case *ast.FunctionStatement:
+		// The declaration was bound when its block was entered.
 		return evalIdentifier(node.Name, env)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.BigIntLiteral:
+		return &object.BigInt{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 	case *ast.ArrayLiteral:
@@ -48,6 +120,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -60,9 +134,26 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 
 		return evalIndexExpression(left, index)
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+
+		return evalIndexExpression(obj, &object.String{Value: node.Member.Value})
+	case *ast.ImportExpression:
+		return evalImportExpression(node, env)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			if len(node.Arguments) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(node.Arguments))
+			}
+
+			return quote(node.Arguments[0], env)
+		}
+
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -72,7 +163,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(function, args, env.Budget())
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
 
@@ -82,6 +173,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return evalPrefixExpression(node.Token.Type, right)
 	case *ast.InfixExpression:
+		if node.Token.Type == token.AND || node.Token.Type == token.OR {
+			return evalLogicalExpression(node, env)
+		}
+
 		left := Eval(node.Left, env)
 
 		if isError(left) {
@@ -95,6 +190,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 
 		return evalInfixExpression(node.Token.Type, left, right)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 	case *ast.IfExpression:
 		return evalIfExpressions(node, env)
 	case *ast.Identifier:
@@ -102,15 +199,26 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Parameters: params,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Env:        env,
+			Body:       body,
+			Name:       node.Name,
+		}
 	}
 
 	return NULL
 }
 
+// evalProgram runs the top level directly in env rather than in a scope of
+// its own, so its bindings outlive the program, as the REPL needs.
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
+	hoistFunctions(program.Statements, env)
+
 	for _, statement := range program.Statements {
 		result = Eval(statement, env)
 
@@ -119,21 +227,38 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 			return result.Value
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return newError("%s outside loop", result.Inspect())
 		}
 	}
 
 	return result
 }
 
+// evalBlockStatement runs the statements of block in env. Eval gives every
+// block its own enclosed environment; function bodies and for loops pass
+// the one holding their parameters or loop variables instead.
+//
+// A let statement that shadows an outer variable binds it in a new
+// environment, so functions created earlier in the block keep seeing the
+// outer one, as they do in the VM where names are resolved when compiled.
+// An empty block evaluates to null.
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
-	var result object.Object
+	var result object.Object = NULL
+
+	hoistFunctions(block.Statements, env)
 
 	for _, statement := range block.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok && shadows(env, let.Name.Value) {
+			env = object.NewEnclosedEnvironment(env)
+		}
+
 		result = Eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+				rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -142,10 +267,54 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+// shadows reports whether binding name in env would hide a variable of an
+// outer environment.
+func shadows(env *object.Environment, name string) bool {
+	if env.Defines(name) {
+		return false
+	}
+
+	_, ok := env.Get(name)
+	return ok
+}
+
+// hoistFunctions binds every function declared among statements before
+// any of them runs, so declarations can refer to each other regardless of
+// their order.
+func hoistFunctions(statements []ast.Statement, env *object.Environment) {
+	for _, statement := range statements {
+		if fs, ok := statement.(*ast.FunctionStatement); ok {
+			env.Set(fs.Name.Value, Eval(fs.Function, env))
+		}
+	}
+}
+
+// evalWhileStatement runs the loop body until the condition is falsy or the
+// body breaks. A while loop itself evaluates to null.
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		switch result := Eval(node.Body, env).(type) {
+		case *object.ReturnValue, *object.Error:
+			return result
+		case *object.Break:
+			return NULL
+		}
+	}
+}
+
 func evalInfixExpression(operator token.TokenType, left object.Object, right object.Object) object.Object {
 	switch {
-	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return object.NumberInfix(operator, left, right)
 	case left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ:
 		return evalBooleanInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
@@ -157,39 +326,37 @@ func evalInfixExpression(operator token.TokenType, left object.Object, right obj
 	}
 }
 
-func evalStringInfixExpression(operator token.TokenType, left object.Object, right object.Object) object.Object {
-	leftValue := left.(*object.String).Value
-	rightValue := right.(*object.String).Value
+// evalLogicalExpression evaluates && and ||. The right operand is only
+// evaluated when the left one does not decide the result, and the operand
+// that decided it is returned as is.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
 
-	switch operator {
-	case token.PLUS:
-		return &object.String{Value: leftValue + rightValue}
-	case token.EQ:
-		return &object.Boolean{Value: leftValue == rightValue}
-	case token.NOT_EQ:
-		return &object.Boolean{Value: leftValue != rightValue}
-	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	if isTruthy(left) == (node.Token.Type == token.OR) {
+		return left
 	}
+
+	return Eval(node.Right, env)
 }
 
-func evalIntegerInfixExpression(operator token.TokenType, left object.Object, right object.Object) object.Object {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+func evalStringInfixExpression(operator token.TokenType, left object.Object, right object.Object) object.Object {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
 
 	switch operator {
 	case token.PLUS:
-		return &object.Integer{Value: leftValue + rightValue}
-	case token.MINUS:
-		return &object.Integer{Value: leftValue - rightValue}
-	case token.ASTERISK:
-		return &object.Integer{Value: leftValue * rightValue}
-	case token.SLASH:
-		return &object.Integer{Value: leftValue / rightValue}
+		return &object.String{Value: leftValue + rightValue}
 	case token.LT:
 		return nativeBoolToBooleanObject(leftValue < rightValue)
 	case token.GT:
 		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case token.LT_EQ:
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case token.GT_EQ:
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
 	case token.EQ:
 		return nativeBoolToBooleanObject(leftValue == rightValue)
 	case token.NOT_EQ:
@@ -200,11 +367,14 @@ func evalIntegerInfixExpression(operator token.TokenType, left object.Object, ri
 }
 
 func evalBooleanInfixExpression(operator token.TokenType, left object.Object, right object.Object) object.Object {
+	leftValue := left.(*object.Boolean).Value
+	rightValue := right.(*object.Boolean).Value
+
 	switch operator {
 	case token.EQ:
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(leftValue == rightValue)
 	case token.NOT_EQ:
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(leftValue != rightValue)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -216,31 +386,42 @@ func evalPrefixExpression(operator token.TokenType, right object.Object) object.
 		return evalBangOperatorExpression(right)
 	case token.MINUS:
 		return evalMinusPrefixOperatorExpression(right)
+	case token.BIT_NOT:
+		return evalBitNotOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
-	default:
-		return FALSE
-	}
+	return nativeBoolToBooleanObject(!isTruthy(right))
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return object.NewInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
+}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+func evalBitNotOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return object.NewInteger(new(big.Int).Not(right.Value))
+	default:
+		return newError("unknown operator: ~%s", right.Type())
+	}
 }
 
 func evalIfExpressions(node *ast.IfExpression, env *object.Environment) object.Object {
@@ -266,6 +447,15 @@ func evalExpressions(
 	var result []object.Object
 
 	for _, e := range exps {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			values := evalSpreadExpression(spread, env)
+			if len(values) == 1 && isError(values[0]) {
+				return values
+			}
+			result = append(result, values...)
+			continue
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -276,27 +466,167 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalSpreadExpression returns the values produced by iterating over the
+// spread operand, using the same convention as evalExpressions for errors.
+func evalSpreadExpression(node *ast.SpreadExpression, env *object.Environment) []object.Object {
+	evaluated := Eval(node.Value, env)
+	if isError(evaluated) {
+		return []object.Object{evaluated}
+	}
+
+	iterable, ok := evaluated.(object.Iterable)
+	if !ok {
+		err := newError("not iterable: %s", evaluated.Type())
+		err.Pos = node.Pos()
+		return []object.Object{err}
+	}
+
+	values := []object.Object{}
+	for iter := iterable.Iter(); ; {
+		// Like a loop iteration, each value counts against the budget.
+		if err := env.Budget().Step(); err != nil {
+			return []object.Object{err}
+		}
+
+		_, value, ok := iter.Next()
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// evalForStatement binds each key and value of the iterable in a new
+// environment for every iteration, which the loop body shares. Like a
+// while loop it evaluates to null.
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	it, ok := iterable.(object.Iterable)
+	if !ok {
+		return newError("not iterable: %s", iterable.Type())
+	}
+
+	iter := it.Iter()
+
+	for {
+		// An empty body evaluates nothing, so the iteration itself has to
+		// count against the budget.
+		if err := env.Budget().Step(); err != nil {
+			return err
+		}
+
+		key, value, ok := iter.Next()
+		if !ok {
+			return NULL
+		}
+
+		iterEnv := object.NewEnclosedEnvironment(env)
+		if node.Key != nil {
+			iterEnv.Set(node.Key.Value, key)
+		}
+		iterEnv.Set(node.Value.Value, value)
+
+		switch result := evalBlockStatement(node.Body, iterEnv).(type) {
+		case *object.ReturnValue, *object.Error:
+			return result
+		case *object.Break:
+			return NULL
+		}
+	}
+}
+
+// Apply calls fn, a function or builtin, with args. Host code uses it to
+// call back into functions a program handed it. The call has no limits,
+// even if fn was created by a run with limits that has since ended.
+func Apply(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, nil)
+}
+
+// caller is the object.CallContext builtins get from the evaluator. Calls
+// they make count against the same budget as the call to the builtin.
+type caller struct {
+	budget *object.Budget
+}
+
+func (c caller) Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, c.budget)
+}
+
+// applyFunction calls fn with args. The call runs under the caller's
+// budget, not the one fn was defined under.
+func applyFunction(fn object.Object, args []object.Object, budget *object.Budget) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendedFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+		if min, max := fn.Arity(); len(args) < min || max >= 0 && len(args) > max {
+			return object.ArityError(fn.Name, min, max, len(args))
+		}
+
+		if err := budget.Enter(); err != nil {
+			return err
+		}
+		defer budget.Leave()
+
+		extendedEnv, err := extendedFunctionEnv(fn, args, budget)
+		if err != nil {
+			return err
+		}
+
+		evaluated := evalBlockStatement(fn.Body, extendedEnv)
+		if evaluated == BREAK || evaluated == CONTINUE {
+			return newError("%s outside loop", evaluated.Inspect())
+		}
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if result := fn.Fn(caller{budget}, args...); result != nil {
+			return result
+		}
+
+		return NULL
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func extendedFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendedFunctionEnv binds the parameters of fn to args. Parameters left
+// without an argument start out as null and then take their default
+// values in order, so a default can refer to the parameters before it.
+func extendedFunctionEnv(fn *object.Function, args []object.Object, budget *object.Budget) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fn.Env)
+	env.SetBudget(budget)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+		} else {
+			env.Set(param.Value, NULL)
+		}
+	}
+
+	for paramIdx := len(args); paramIdx < len(fn.Defaults); paramIdx++ {
+		value := Eval(fn.Defaults[paramIdx], env)
+		if isError(value) {
+			return nil, value
+		}
+
+		env.Set(fn.Parameters[paramIdx].Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
 
-	return env
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -323,6 +653,12 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		return left.(*object.Module).Export(index.(*object.String).Value)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -344,6 +680,173 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpression returns the rune at index as a one-character
+// string. Indices count runes, not bytes.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	max := int64(len(runes) - 1)
+
+	if idx < 0 {
+		idx = max + 1 + idx
+	}
+
+	if idx < 0 || idx > max {
+		return NULL
+	}
+
+	return &object.String{Value: string(runes[idx])}
+}
+
+// compoundOperators maps each compound assignment operator to the infix
+// operator it applies.
+var compoundOperators = map[token.TokenType]token.TokenType{
+	token.PLUS_ASSIGN:     token.PLUS,
+	token.MINUS_ASSIGN:    token.MINUS,
+	token.ASTERISK_ASSIGN: token.ASTERISK,
+	token.SLASH_ASSIGN:    token.SLASH,
+}
+
+// evalAssignExpression stores the value of node into its target. For a
+// compound operator the target's current value is read before the
+// right-hand side is evaluated.
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	operator, compound := compoundOperators[node.Token.Type]
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if compound {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+
+		val := evalAssignedValue(node.Value, env, operator, current)
+		if isError(val) {
+			return val
+		}
+
+		if _, ok := env.Assign(target.Value, val); !ok {
+			return newError("assignment to undeclared variable: %s", target.Value)
+		}
+
+		return val
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		if compound {
+			current = evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		val := evalAssignedValue(node.Value, env, operator, current)
+		if isError(val) {
+			return val
+		}
+
+		return evalIndexAssignment(left, index, val)
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalAssignedValue evaluates the right-hand side of an assignment and,
+// when current is not nil, combines the two with operator.
+func evalAssignedValue(value ast.Expression, env *object.Environment, operator token.TokenType, current object.Object) object.Object {
+	val := Eval(value, env)
+	if isError(val) || current == nil {
+		return val
+	}
+
+	return evalInfixExpression(operator, current, val)
+}
+
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		max := int64(len(arrayObject.Elements) - 1)
+
+		if idx < 0 {
+			idx = max + 1 + idx
+		}
+
+		if idx < 0 || idx > max {
+			return newError("index out of range: %d", index.(*object.Integer).Value)
+		}
+
+		arrayObject.Elements[idx] = val
+
+		return val
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		left.(*object.Hash).Set(key.HashKey(), object.HashPair{Key: index, Value: val})
+
+		return val
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Get(key.HashKey())
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -353,12 +856,10 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 }
 
 func isTruthy(obj object.Object) bool {
-	switch obj {
-	case TRUE:
-		return true
-	case NULL:
-		return false
-	case FALSE:
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
 		return false
 	default:
 		return true
@@ -1,10 +1,13 @@
 package evaluator
 
 import (
+	"context"
 	"gomonkey/lexer"
 	"gomonkey/object"
 	"gomonkey/parser"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -27,6 +30,30 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"-7 / 2", -3},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"2 * 3 ** 2", 18},
+		{"5 ** 0", 1},
+		{"10 % 4 * 3", 6},
+		{"0xF0 | 0x0F", 255},
+		{"0b1100 & 0b1010", 8},
+		{"0b1100 ^ 0b1010", 6},
+		{"~0", -1},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 >> 100", 0},
+		{"-1 >> 100", -1},
+		{"0 << 100", 0},
+		{"1_000 * 0o10", 8000},
+		{"1 | 2 ^ 3 & 4", 3},
+		{"let flags = 0; flags = flags | 1 << 3; flags & 8", 8},
 	}
 
 	for _, tt := range tests {
@@ -35,6 +62,153 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"0.5", 0.5},
+		{"1e-3", 0.001},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5e1", -15.0},
+		{"7.5 % 2", 1.5},
+		{"2 ** -1", 0.5},
+		{"4 ** 0.5", 2.0},
+		{"1.5 ** 2", 2.25},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775808 - 1", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-(-9223372036854775808)", "9223372036854775808"},
+		{"-9223372036854775808 / -1", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"123456789012345678901234567890 - 123456789012345678901234567890 + 7", "7"},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)", "15511210043330985984000000"},
+		{"99999999999999999999 > 9223372036854775807", "true"},
+		{"99999999999999999999 == 99999999999999999999", "true"},
+		{"99999999999999999999 + 0.5", "1e+20"},
+		{"2 ** 64", "18446744073709551616"},
+		{"3 ** 40", "12157665459056928801"},
+		{"(-2) ** 63", "-9223372036854775808"},
+		{"2 ** 63", "9223372036854775808"},
+		{"99999999999999999999 % 7", "1"},
+		{"99999999999999999999 ** 2 / 99999999999999999999", "99999999999999999999"},
+		{"99999999999999999999 >= 99999999999999999999", "true"},
+		{"1 << 63", "9223372036854775808"},
+		{"3 << 64", "55340232221128654848"},
+		{"(1 << 100) >> 99", "2"},
+		{"~99999999999999999999", "-100000000000000000000"},
+		{"(1 << 64) - 1 & 0xFF", "255"},
+		{"0xFFFF_FFFF_FFFF_FFFF", "18446744073709551615"},
+		{"(1 << 70) | 1", "1180591620717411303425"},
+		{"(1 << 70) ^ (1 << 70)", "0"},
+		{"99999999999999999999 <= 1", "false"},
+		{"int(1e20)", "100000000000000000000"},
+		{`{99999999999999999999: "big"}[99999999999999999999]`, "big"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, got=%s (%T)", tt.input, tt.expected, evaluated.Inspect(), evaluated)
+		}
+	}
+}
+
+func TestBigIntegerResultsAreDemoted(t *testing.T) {
+	evaluated := testEval("9223372036854775807 + 1 - 1")
+	testIntegerObject(t, evaluated, 9223372036854775807)
+}
+
+func TestMixedNumberComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"0.5 < 1", true},
+		{"2 > 2.5", false},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1 <= 1.5", true},
+		{"2.5 >= 2.5", true},
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"abc" > "abd"`, false},
+		{`"ab" < "abc"`, true},
+		{`"b" <= "b"`, true},
+		{`"a" >= "b"`, false},
+		{`"Z" < "a"`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNumberConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int("42")`, 42},
+		{`int(7)`, 7},
+		{`float(2)`, 2.0},
+		{`float("0.25")`, 0.25},
+		{`round(2.5)`, 3},
+		{`round(-2.5)`, -3},
+		{`floor(2.7)`, 2},
+		{`floor(-2.1)`, -3},
+		{`ceil(2.1)`, 3},
+		{`ceil(5)`, 5},
+		{`int("abc")`, "could not convert \"abc\" to INTEGER"},
+		{`float(true)`, "`float` builtin function doesn't support argument of type BOOLEAN"},
+		{`round("1")`, "argument to `round` must be INTEGER or FLOAT, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -90,6 +264,51 @@ func TestBangOperator(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && false", false},
+		{"true || false", true},
+		{"false || false", false},
+		{"1 && 2", 2},
+		{"0 && 2", 2},
+		{"false && 2", false},
+		{"1 || 2", 1},
+		{"if (false) { 1 } || 3", 3},
+		{"if (false) { 1 } && 3", nil},
+		{"1 < 2 && 2 < 3", true},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"true && undefined", "identifier not found: undefined"},
+		{"let n = 0; let f = fn() { n += 1; true }; false && f(); true || f(); n", 0},
+		{"let n = 0; let f = fn() { n += 1; true }; true && f(); false || f(); n", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
 func TestIfElseExpressions(t *testing.T) {
 	tests := []struct {
 		expected interface{}
@@ -155,6 +374,58 @@ func TestErrorHandling(t *testing.T) {
 			input:           "5 + true; 5;",
 			expectedMessage: "type mismatch: INTEGER + BOOLEAN",
 		},
+		{
+			input:           "1 / 0",
+			expectedMessage: "division by zero",
+		},
+		{
+			input:           "1 % 0",
+			expectedMessage: "modulo by zero",
+		},
+		{
+			input:           "99999999999999999999 / 0",
+			expectedMessage: "division by zero",
+		},
+		{
+			input:           "99999999999999999999 % 0",
+			expectedMessage: "modulo by zero",
+		},
+		{
+			input:           "1 << -1",
+			expectedMessage: "negative shift count: -1",
+		},
+		{
+			input:           "1 >> 99999999999999999999",
+			expectedMessage: "shift count too large: 99999999999999999999",
+		},
+		{
+			input:           "1 << 100000000000",
+			expectedMessage: "shift result too large: more than 1048576 bits",
+		},
+		{
+			input:           "99999999999999999999 << 1048560",
+			expectedMessage: "shift result too large: more than 1048576 bits",
+		},
+		{
+			input:           "1.5 & 1",
+			expectedMessage: "unknown operator: FLOAT & INTEGER",
+		},
+		{
+			input:           "~true",
+			expectedMessage: "unknown operator: ~BOOLEAN",
+		},
+		{
+			input:           "~1.5",
+			expectedMessage: "unknown operator: ~FLOAT",
+		},
+		{
+			input:           "true <= false",
+			expectedMessage: "unknown operator: BOOLEAN <= BOOLEAN",
+		},
+		{
+			input:           `"a" % "b"`,
+			expectedMessage: "unknown operator: STRING % STRING",
+		},
 		{
 			input:           "-true",
 			expectedMessage: "unknown operator: -BOOLEAN",
@@ -207,6 +478,207 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true", "ERROR: 1:1: type mismatch: INTEGER + BOOLEAN"},
+		{"let a = 1;\n\nlet b = a + foo;", "ERROR: 3:13: identifier not found: foo"},
+		{"let f = fn(x) {\n  x + true\n};\nf(1)", "ERROR: 2:3: type mismatch: INTEGER + BOOLEAN"},
+		{"len(1)", "ERROR: 1:1: `len` builtin function doesn't support argument of type INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if _, ok := evaluated.(*object.Error); !ok {
+			t.Errorf("test: %s. no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestWhileLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; let sum = 0; let step = fn() { let sum = sum + i; sum }; while (i < 3) { sum = step(); i = i + 1; }; sum", 3},
+		{"let i = 0; while (true) { i = i + 1; if (i > 4) { break; } }; i", 5},
+		{"let i = 0; let odd = 0; while (i < 10) { i = i + 1; if (i / 2 * 2 == i) { continue; } odd = odd + 1; }; odd", 5},
+		{"let f = fn() { let i = 0; while (true) { i = i + 1; if (i == 7) { return i * 2; } } }; f()", 14},
+		{"while (false) { 1 }", nil},
+		{"let i = 0; while (i < 3) { let j = 0; while (true) { j = j + 1; if (j > 10) { break; } } i = i + 1; }; i", 3},
+		{"while (1 + true) { }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestForInLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum = sum + x; }; sum", 6},
+		{"let sum = 0; for (i, x in [10, 20, 30]) { sum = sum + i * x; }; sum", 80},
+		{`let out = ""; for (ch in "héllo") { out = ch + out; }; out`, "olléh"},
+		{`let last = 0; for (i, ch in "héllo") { last = i; }; last`, 4},
+		{`let sum = 0; for (v in {"a": 1, "b": 2}) { sum = sum + v; }; sum`, 3},
+		{`let out = ""; for (v in {"a": "x", "b": "y"}) { out = out + v; }; out`, "xy"},
+		{`let out = ""; for (k, v in {"a": 1, "b": 2}) { out = out + k; }; out`, "ab"},
+		{`let sum = 0; for (k, v in {"a": 1, "b": 2}) { sum = sum + v; }; sum`, 3},
+		{"let sum = 0; for (x in range(5)) { sum = sum + x; }; sum", 10},
+		{"let sum = 0; for (x in range(10, 0, -3)) { sum = sum + x; }; sum", 22},
+		{"let n = 0; for (x in range(1000000000000)) { if (x == 3) { break; } n = n + 1; }; n", 3},
+		{"let n = 0; for (x in range(10)) { if (x / 2 * 2 == x) { continue; } n = n + 1; }; n", 5},
+		{"let f = fn(arr) { for (x in arr) { if (x > 1) { return x; } } }; f([1, 5, 7])", 5},
+		{"for (x in []) { x }", nil},
+		{"for (x in 5) { x }", "not iterable: INTEGER"},
+		{`range(0, 1, 0)`, "`range` step must not be zero"},
+		{`len(range(0, 10, 3))`, 4},
+		{`len(range(5, 0))`, 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string for %s. want=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 1; let y = 2; x = y = 5; x + y", 10},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{"let s = \"a\"; s += \"b\"; s", "ab"},
+		{"let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next(); next()", 3},
+		{"let x = 1; let f = fn() { x = 5 }; f(); x", 5},
+		{"let x = 1; let f = fn() { let x = 2; x = 3; x }; f() + x", 4},
+		{"let i = 0; let sum = 0; while (i < 4) { sum += i; i += 1 }; sum", 6},
+		{"let a = [1, 2, 3]; a[0] = 10; a[-1] += 5; a[0] + a[2]", 18},
+		{"let a = [1, 2]; let b = a; b[1] = 7; a[1]", 7},
+		{"let h = {\"a\": 1}; h[\"a\"] += 1; h[\"b\"] = 5; h[\"a\"] + h[\"b\"]", 7},
+		{"let h = {}; h[true] = 1; len(keys(h))", 1},
+		{"let a = [[1], [2]]; a[1][0] = 9; a[1][0]", 9},
+		{"x = 1", "assignment to undeclared variable: x"},
+		{"let f = fn() { y = 1 }; f()", "assignment to undeclared variable: y"},
+		{"len = 1", "assignment to undeclared variable: len"},
+		{"y += 1", "identifier not found: y"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{"let a = [1]; a[-2] = 2", "index out of range: -2"},
+		{"let s = \"ab\"; s[0] = \"c\"", "index assignment not supported: STRING"},
+		{"let h = {}; h[fn(x) { x }] = 1", "unusable as hash key: FUNCTION"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string. expected=%q, got=%q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestBlockScoping(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; if (true) { let x = 2; }; x", "1"},
+		{"let x = 1; if (true) { let x = 2; x }", "2"},
+		{"let x = 1; if (false) { } else { let x = 2; }; x", "1"},
+		{"let x = 1; if (true) { x = 2; }; x", "2"},
+		{"let x = 1; if (true) { let x = x + 1; x }", "2"},
+		{"let x = 1; if (true) { let x = 2; if (true) { x = 3; } x }", "3"},
+		{"let x = 1; if (true) { let x = 2; if (true) { let x = 3; } x }", "2"},
+		{"if (true) { let y = 1; }; y", "ERROR: 1:27: identifier not found: y"},
+		{"if (true) { fn f() { 1 } }; f()", "ERROR: 1:29: identifier not found: f"},
+		{"let f = fn() { let x = 1; if (true) { let x = 2; }; x }; f()", "1"},
+		{"if (true) { let x = 1; let g = fn() { x }; let x = 2; g() }", "2"},
+		{"let x = 1; if (true) { let g = fn() { x }; let x = 2; g() }", "1"},
+		{"let x = 1; if (true) { let g = fn() { x }; let x = 2; [g(), x] }", "[1, 2]"},
+		{"let x = 1; if (true) { let x = 2; fn g() { x } g() }", "1"},
+		{"let x = 1; while (true) { let g = fn() { x }; let x = 2; break }; x", "1"},
+		{"let i = 0; let seen = []; while (i < 3) { let v = i * 2; seen = push(seen, v); i += 1 }; seen", "[0, 2, 4]"},
+		{"let i = 0; while (i < 2) { i += 1; let i = 10; }; i", "2"},
+		{"for (x in [1]) { }; x", "ERROR: 1:21: identifier not found: x"},
+		{"let x = 10; for (x in [1, 2]) { }; x", "10"},
+		{"let n = 0; for (x in [1, 2]) { let n = x; }; n", "0"},
+		{"let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }) }; [fs[0](), fs[1](), fs[2]()]", "[0, 1, 2]"},
+		{"let fs = []; let i = 0; while (i < 3) { let j = i; fs = push(fs, fn() { j }); i += 1 }; [fs[0](), fs[2]()]", "[0, 2]"},
+		{"let x = 1; let x = 2; x", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -259,6 +731,9 @@ func TestFunctionApplication(t *testing.T) {
 		{input: "let add = fn(x, y) { x + y; }; add(5, 5);", expected: 10},
 		{input: "let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", expected: 20},
 		{input: "fn(x) { x; }(5)", expected: 5},
+		{input: "let x = 1; let f = fn() { let g = fn() { x }; let x = 2; g() }; f()", expected: 1},
+		{input: "let x = 1; let f = fn() { let g = fn() { x }; let x = 2; g() + x }; f()", expected: 3},
+		{input: "let f = fn(x) { let g = fn() { x }; let x = 5; g() }; f(2)", expected: 5},
 	}
 
 	for _, tt := range tests {
@@ -266,6 +741,238 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionArity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(a, b) { a + b }; add(1)", "wrong number of arguments to add: want=2, got=1"},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3)", "wrong number of arguments to add: want=2, got=3"},
+		{"fn(a) { a }()", "wrong number of arguments: want=1, got=0"},
+		{"fn() { 1 }(1)", "wrong number of arguments: want=0, got=1"},
+		{"let outer = fn() { let inner = fn(x) { x }; inner() }; outer()", "wrong number of arguments to inner: want=1, got=0"},
+		{"let f = fn(x) { x }; let g = f; g()", "wrong number of arguments to f: want=1, got=0"},
+		{"let f = fn(a, b = 2) { a }; f(1, 2, 3)", "wrong number of arguments to f: want=1 to 2, got=3"},
+		{"let f = fn(a, ...rest) { a }; f()", "wrong number of arguments to f: want=at least 1, got=0"},
+		{"let f = fn(a, b) { a }; f(...[1, 2, 3])", "wrong number of arguments to f: want=2, got=3"},
+		{"let f = fn(...rest) { rest }; f(...5)", "not iterable: INTEGER"},
+		{"fn f(a) { a } f()", "wrong number of arguments to f: want=1, got=0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", "11"},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", "3"},
+		{"let f = fn(a = 1, b = a * 2) { [a, b] }; f()", "[1, 2]"},
+		{"let f = fn(a = 1, b = a * 2) { [a, b] }; f(5)", "[5, 10]"},
+		{"let n = 1; let f = fn(x = n) { x }; n = 2; f()", "2"},
+		{"let calls = 0; let f = fn(x = fn() { calls += 1 }()) { x }; f(); f(9); f(); calls", "2"},
+		{"let f = fn(xs = []) { push(xs, 1) }; f(); f()", "[1]"},
+		{"let f = fn(...rest) { rest }; f()", "[]"},
+		{"let f = fn(a, ...rest) { [a, rest] }; f(1, 2, 3)", "[1, [2, 3]]"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1)", "[1, 2, []]"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 3, 4)", "[1, 3, [4]]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(a, b, c) { a + b + c }; add(...[1, 2, 3])", "6"},
+		{"let add = fn(a, b, c) { a + b + c }; add(1, ...[2], ...[3])", "6"},
+		{"let f = fn(...xs) { xs }; f(...[], 1, ...[2, 3])", "[1, 2, 3]"},
+		{"let xs = [2, 3]; [1, ...xs, 4]", "[1, 2, 3, 4]"},
+		{"[...[], ...[]]", "[]"},
+		{`[..."héllo"]`, "[h, é, l, l, o]"},
+		{"[...range(3)]", "[0, 1, 2]"},
+		{`len([...{"a": 1, "b": 2}])`, "2"},
+		{"len(...[[1, 2]])", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn add(a, b) { a + b } add(1, 2)", "3"},
+		{"let r = add(1, 2); fn add(a, b) { a + b }; r", "3"},
+		{"fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } } fact(5)", "120"},
+		{`
+		let r = [isEven(10), isOdd(7), isEven(3)];
+		fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+		fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+		r`, "[true, true, false]"},
+		{`
+		let outer = fn(n) {
+			let r = even(n);
+			fn even(n) { if (n == 0) { true } else { odd(n - 1) } }
+			fn odd(n) { if (n == 0) { false } else { even(n - 1) } }
+			r
+		};
+		outer(4)`, "true"},
+		{"let x = 1; fn get() { x } x = 2; get()", "2"},
+		{"fn id(x) { x }", "fn id(x) {\nx\n}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestEvalContextLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   Limits
+		expected string
+		kind     object.ErrorKind
+	}{
+		{"while (true) { }", context.Background(), Limits{MaxSteps: 1000}, "step limit of 1000 exceeded", object.LimitError},
+		{"for (x in range(1000000000000)) { }", context.Background(), Limits{MaxSteps: 1000}, "step limit of 1000 exceeded", object.LimitError},
+		{"let f = fn(x) { f(x) }; f(1)", context.Background(), Limits{MaxDepth: 100}, "call depth limit of 100 exceeded", object.LimitError},
+		{"while (true) { }", context.Background(), Limits{Timeout: 10 * time.Millisecond}, "evaluation stopped: context deadline exceeded", object.LimitError},
+		{"let f = fn() { 1 }; f()", cancelled, Limits{}, "evaluation stopped: context canceled", object.LimitError},
+		{"let f = fn(x) { map([x], f) }; f(1)", context.Background(), Limits{MaxDepth: 100}, "call depth limit of 100 exceeded", object.LimitError},
+		{"1 + true", context.Background(), Limits{MaxSteps: 1000, MaxDepth: 10}, "type mismatch: INTEGER + BOOLEAN", object.RuntimeError},
+		{"len([...range(30000000)])", context.Background(), Limits{MaxSteps: 100}, "step limit of 100 exceeded", object.LimitError},
+		{"len([...range(1000000000000)])", context.Background(), Limits{Timeout: 10 * time.Millisecond}, "evaluation stopped: context deadline exceeded", object.LimitError},
+		{"puts(...range(30000000))", context.Background(), Limits{MaxSteps: 100}, "step limit of 100 exceeded", object.LimitError},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(tt.ctx, program, object.NewEnvironment(), tt.limits)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expected || errObj.Kind != tt.kind {
+			t.Errorf("wrong error for %q. want=%q (kind %d), got=%q (kind %d)",
+				tt.input, tt.expected, tt.kind, errObj.Message, errObj.Kind)
+		}
+	}
+}
+
+func TestEvalContextWithinLimits(t *testing.T) {
+	input := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)"
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	limits := Limits{MaxSteps: 1000000, MaxDepth: 20, Timeout: time.Minute}
+	evaluated := EvalContext(context.Background(), program, object.NewEnvironment(), limits)
+
+	testIntegerObject(t, evaluated, 610)
+}
+
+func TestEvalContextLimitsEndWithTheCall(t *testing.T) {
+	env := object.NewEnvironment()
+
+	define := parser.New(lexer.New("let count = fn(n) { let i = 0; while (i < n) { i += 1 }; i };")).ParseProgram()
+	EvalContext(context.Background(), define, env, Limits{MaxSteps: 1000})
+
+	call := parser.New(lexer.New("count(1000)")).ParseProgram()
+
+	evaluated := EvalContext(context.Background(), call, env, Limits{MaxSteps: 100})
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Kind != object.LimitError {
+		t.Fatalf("closure did not run under the caller's budget. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	testIntegerObject(t, Eval(call, env), 1000)
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+		{"map([], fn(x) { x })", "[]"},
+		{`map(["a", "bc"], len)`, "[1, 2]"},
+		{"sort([3, 1, 2])", "[1, 2, 3]"},
+		{"sort([2, 1.5, -1])", "[-1, 1.5, 2]"},
+		{`sort(["pear", "apple"])`, "[apple, pear]"},
+		{"let xs = [2, 1]; sort(xs); xs", "[2, 1]"},
+		{`sort([[2, "b"], [1, "a"], [2, "a"]], fn(a, b) { a[0] < b[0] })`, "[[1, a], [2, b], [2, a]]"},
+		{"map(1, len)", "ERROR: first argument to `map` must be ARRAY, got INTEGER"},
+		{"map([1], 2)", "ERROR: not a function: INTEGER"},
+		{"map([1], fn(x) { x + true })", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"sort([1, true])", "ERROR: `sort` cannot compare BOOLEAN and INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = "ERROR: " + errObj.Message
+		}
+
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New("let k = 3; let scale = fn(x) { x * k };")).ParseProgram(), env)
+
+	scale, _ := env.Get("scale")
+	testIntegerObject(t, Apply(scale, &object.Integer{Value: 2}), 6)
+	testIntegerObject(t, Apply(object.GetBuiltinByName("len"), &object.String{Value: "four"}), 4)
+
+	if errObj, ok := Apply(scale).(*object.Error); !ok || errObj.Message != "wrong number of arguments to scale: want=1, got=0" {
+		t.Errorf("wrong result calling with too few arguments. got=%v", Apply(scale))
+	}
+
+	program := parser.New(lexer.New("let make = fn() { fn(x) { x + 1 } }; make()")).ParseProgram()
+	limited := EvalContext(context.Background(), program, object.NewEnvironment(), Limits{Timeout: time.Second, MaxSteps: 1000})
+	testIntegerObject(t, Apply(limited, &object.Integer{Value: 41}), 42)
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!";`
 
@@ -281,6 +988,79 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"line\n\t\"quoted\"\\"`, "line\n\t\"quoted\"\\"},
+		{`"caf\u{E9}"`, "café"},
+		{"`C:\\raw\\n\nnext`", "C:\\raw\\n\nnext"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("héllo")`, 5},
+		{`bytes_len("héllo")`, 6},
+		{`len("🙈🙉🙊")`, 3},
+		{`"héllo"[1]`, "é"},
+		{`"🙈🙉🙊"[-1]`, "🙊"},
+		{`"abc"[3]`, nil},
+		{`slice("héllo wörld", 6)`, "wörld"},
+		{`slice("héllo", 1, 3)`, "él"},
+		{`slice("héllo", -3, -1)`, "ll"},
+		{`slice("abc", 2, 1)`, ""},
+		{`slice("abc", -10, 10)`, "abc"},
+		{`len(slice([1, 2, 3, 4], 1, 3))`, 2},
+		{`let préço = 5; préço * 2`, 10},
+		{`bytes_len(1)`, "argument to `bytes_len` must be STRING, got INTEGER"},
+		{`slice(1, 2)`, "argument to `slice` must be STRING or ARRAY, got INTEGER"},
+		{`slice("abc", "a")`, "slice bounds must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string for %s. want=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!";`
 
@@ -441,6 +1221,134 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	if inspect := result.Inspect(); inspect != "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}" {
+		t.Errorf("Hash.Inspect() is not in insertion order. got=%q", inspect)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		expected interface{}
+		input    string
+	}{
+		{5, `{"foo": 5}["foo"]`},
+		{nil, `{"foo": 5}["bar"]`},
+		{5, `let key = "foo"; {"foo": 5}[key]`},
+		{nil, `{}["foo"]`},
+		{5, `{5: 5}[5]`},
+		{5, `{true: 5}[true]`},
+		{5, `{false: 5}[false]`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`has({}, [])`, "unusable as hash key: ARRAY"},
+		{`keys([])`, "argument to `keys` must be HASH, got ARRAY"},
+		{`put({}, 1)`, "wrong number of arguments. got=2, want=3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("test: %s. no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestHashBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len({})`, "0"},
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`keys({"a": 1, 2: "b", true: 3})`, "[a, 2, true]"},
+		{`values({"a": 1, 2: "b", true: 3})`, "[1, b, 3]"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`put({"a": 1}, "b", 2)`, "{a: 1, b: 2}"},
+		{`put({"a": 1}, "a", 2)`, "{a: 2}"},
+		{`let h = {"a": 1}; put(h, "b", 2); h`, "{a: 1}"},
+		{`delete({"a": 1, "b": 2}, "a")`, "{b: 2}"},
+		{`delete({"a": 1}, "b")`, "{a: 1}"},
+		{`let h = {"a": 1}; delete(h, "a"); h`, "{a: 1}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("input %q: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	if testEval(`has({"a": 1}, "a")`) != TRUE || testEval(`has({}, "a")`) != FALSE {
+		t.Errorf("has does not return the shared booleans")
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -467,6 +1375,22 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 
@@ -491,3 +1415,59 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 
 	return true
 }
+
+var testModules = fstest.MapFS{
+	"math.mk":     {Data: []byte("let pi = 3; let _hidden = 1; fn square(x) { x * x }; let area = fn(r) { pi * square(r) };")},
+	"counter.mk":  {Data: []byte("let count = 0; let next = fn() { count = count + 1; count };")},
+	"uses.mk":     {Data: []byte(`let m = import "math"; let double_area = fn(r) { 2 * m.area(r) };`)},
+	"cycle_a.mk":  {Data: []byte(`let b = import "cycle_b";`)},
+	"cycle_b.mk":  {Data: []byte(`let a = import "cycle_a";`)},
+	"broken.mk":   {Data: []byte("let x = 1;\nlet y = x + true;")},
+	"invalid.mk":  {Data: []byte("let = 1;")},
+	"lib/util.mk": {Data: []byte("let twice = fn(f, x) { f(f(x)) };")},
+}
+
+func TestImports(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = import "math"; m.area(2)`, "12"},
+		{`let m = import "math"; m.square(3)`, "9"},
+		{`let m = import "math"; m["pi"]`, "3"},
+		{`import "math"`, "<module math.mk>"},
+		{`(import "uses").double_area(1)`, "6"},
+		{`let u = import "lib/util"; u.twice(fn(x) { x * 10 }, 2)`, "200"},
+		{`let a = import "counter"; let b = import "./counter.mk"; a.next(); b.next()`, "2"},
+		{`let c = import "counter"; let before = c.count; c.next(); c.next(); [before, c.count]`, "[0, 2]"},
+		{`let pi = 100; let m = import "math"; m.area(1)`, "3"},
+		{`let m = import "math"; m._hidden`, "ERROR: 1:24: module math.mk has no export _hidden"},
+		{`let m = import "math"; m.nope`, "ERROR: 1:24: module math.mk has no export nope"},
+		{`import "cycle_a"`, "ERROR: cycle_b.mk:1:9: import cycle: cycle_a.mk -> cycle_b.mk -> cycle_a.mk"},
+		{`import "missing"`, `ERROR: 1:1: cannot find module "missing"`},
+		{`import "broken"`, "ERROR: broken.mk:2:9: type mismatch: INTEGER + BOOLEAN"},
+		{`import "invalid"`, "ERROR: invalid.mk:1:5: expected next token to be IDENT, got = instead"},
+		{`let h = {"a": 1}; h.a`, "1"},
+		{`let h = {"a": 1}; h.b`, "null"},
+		{`let n = 1; n.a`, "ERROR: 1:12: index operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetModules(object.NewModules(object.NewFSResolver(testModules), nil))
+
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestImportWithoutModules(t *testing.T) {
+	evaluated := testEval(`import "math"`)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != `cannot import "math": no module resolver` {
+		t.Errorf("wrong result. got=%s", evaluated.Inspect())
+	}
+}
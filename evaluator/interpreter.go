@@ -0,0 +1,220 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gomonkey/ast"
+	"gomonkey/lexer"
+	"gomonkey/object"
+	"gomonkey/parser"
+	"io"
+	"sync"
+)
+
+// Interpreter is an isolated instance of the language for embedding in a
+// host program. It owns its globals, builtins, output writers and exit
+// hook, so several interpreters can run side by side. An Interpreter may
+// be used from several goroutines, but only one call runs at a time; a
+// builtin must not call back into the interpreter that is running it, and
+// calls functions through its object.CallContext instead.
+type Interpreter struct {
+	mu       sync.Mutex
+	globals  *object.Environment
+	builtins *object.Environment
+	macros   *object.Environment
+	stdout   io.Writer
+	stderr   io.Writer
+	exit     func(code int)
+	limits   Limits
+	resolver object.Resolver
+}
+
+type Option func(*Interpreter)
+
+// WithStdout sends the output of `puts` to w instead of discarding it.
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stdout = w
+	}
+}
+
+// WithStderr sends the output of `eputs` to w instead of discarding it.
+func WithStderr(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stderr = w
+	}
+}
+
+// WithExit calls exit with the status code when a program calls `exit`.
+// The program stops either way, with an error of kind object.ExitError.
+func WithExit(exit func(code int)) Option {
+	return func(i *Interpreter) {
+		i.exit = exit
+	}
+}
+
+// WithLimits applies limits to every Run, Eval and Call.
+func WithLimits(limits Limits) Option {
+	return func(i *Interpreter) {
+		i.limits = limits
+	}
+}
+
+// WithResolver lets programs import the modules resolver finds. Modules
+// share the interpreter's builtins and are evaluated once per interpreter.
+func WithResolver(resolver object.Resolver) Option {
+	return func(i *Interpreter) {
+		i.resolver = resolver
+	}
+}
+
+func NewInterpreter(opts ...Option) *Interpreter {
+	i := &Interpreter{stdout: io.Discard, stderr: io.Discard}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	i.builtins = object.NewEnvironment()
+	for _, def := range object.Builtins {
+		i.builtins.Set(def.Name, def.Builtin)
+	}
+
+	i.builtins.Set("puts", &object.Builtin{Name: "puts", Fn: object.PutsBuiltin("puts", i.stdout)})
+	i.builtins.Set("eputs", &object.Builtin{Name: "eputs", Fn: object.PutsBuiltin("eputs", i.stderr)})
+	i.builtins.Set("exit", &object.Builtin{Name: "exit", Fn: object.ExitBuiltin(func(code int) object.Object {
+		if i.exit != nil {
+			i.exit(code)
+		}
+
+		return &object.Error{Message: fmt.Sprintf("exit status %d", code), Kind: object.ExitError}
+	})})
+
+	i.globals = object.NewEnclosedEnvironment(i.builtins)
+	if i.resolver != nil {
+		i.globals.SetModules(object.NewModules(i.resolver, i.builtins))
+	}
+	i.macros = object.NewEnvironment()
+
+	return i
+}
+
+// Run parses and evaluates src. Syntax errors are returned joined
+// together as *parser.ParseError values; evaluation errors as an
+// *object.Error.
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	return i.RunContext(context.Background(), src)
+}
+
+// RunContext is like Run, but stops evaluating once ctx is done.
+func (i *Interpreter) RunContext(ctx context.Context, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if parseErrors := p.ParseErrors(); len(parseErrors) != 0 {
+		errs := make([]error, len(parseErrors))
+		for n, err := range parseErrors {
+			errs[n] = err
+		}
+
+		return nil, errors.Join(errs...)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	DefineMacros(program, i.macros)
+	expanded, err := ExpandMacros(program, i.macros)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.eval(ctx, expanded)
+}
+
+// Eval evaluates an already parsed node in the interpreter's globals.
+func (i *Interpreter) Eval(node ast.Node) (object.Object, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.eval(context.Background(), node)
+}
+
+func (i *Interpreter) eval(ctx context.Context, node ast.Node) (object.Object, error) {
+	return result(EvalContext(ctx, node, i.globals, i.limits))
+}
+
+// Call calls the function bound to the global name with args.
+func (i *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	fn, ok := i.globals.Get(name)
+	if !ok {
+		return nil, &object.Error{Message: "identifier not found: " + name}
+	}
+
+	return i.apply(fn, args)
+}
+
+// Apply calls fn, a function or builtin such as one a program passed to a
+// registered Go function, with args.
+func (i *Interpreter) Apply(fn object.Object, args ...object.Object) (object.Object, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.apply(fn, args)
+}
+
+func (i *Interpreter) apply(fn object.Object, args []object.Object) (object.Object, error) {
+	return result(withLimits(context.Background(), i.globals, i.limits, func() object.Object {
+		return applyFunction(fn, args, i.globals.Budget())
+	}))
+}
+
+// Set binds a global, which programs run afterwards can use.
+func (i *Interpreter) Set(name string, value object.Object) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.globals.Set(name, value)
+}
+
+// RegisterFunc makes the Go function fn available to programs as a
+// builtin called name. See object.GoFunc for how arguments and results
+// are converted.
+func (i *Interpreter) RegisterFunc(name string, fn any) error {
+	builtin, err := object.GoFunc(name, fn)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.builtins.Set(name, builtin)
+
+	return nil
+}
+
+// Get returns the value of a global or builtin.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.globals.Get(name)
+}
+
+// result splits an evaluation result into a value and a Go error.
+func result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, err
+	}
+
+	if obj == nil {
+		return NULL, nil
+	}
+
+	return obj, nil
+}
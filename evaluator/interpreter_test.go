@@ -0,0 +1,248 @@
+package evaluator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gomonkey/object"
+	"gomonkey/parser"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInterpreterOutput(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	interp := NewInterpreter(WithStdout(&stdout), WithStderr(&stderr))
+
+	if _, err := interp.Run(`puts("hello", 1); eputs("oops")`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if stdout.String() != "hello\n1\n" {
+		t.Errorf("stdout wrong. got=%q", stdout.String())
+	}
+
+	if stderr.String() != "oops\n" {
+		t.Errorf("stderr wrong. got=%q", stderr.String())
+	}
+}
+
+func TestInterpreterExit(t *testing.T) {
+	var stdout bytes.Buffer
+	code := -1
+	interp := NewInterpreter(WithStdout(&stdout), WithExit(func(c int) { code = c }))
+
+	_, err := interp.Run(`puts("before"); exit(3); puts("after")`)
+
+	var objErr *object.Error
+	if !errors.As(err, &objErr) || objErr.Kind != object.ExitError {
+		t.Fatalf("expected an exit error. got=%v", err)
+	}
+
+	if code != 3 {
+		t.Errorf("exit hook got code %d, want 3", code)
+	}
+
+	if stdout.String() != "before\n" {
+		t.Errorf("stdout wrong. got=%q", stdout.String())
+	}
+}
+
+func TestInterpreterSetGetCall(t *testing.T) {
+	interp := NewInterpreter()
+	interp.Set("base", &object.Integer{Value: 10})
+
+	if _, err := interp.Run(`let add = fn(x) { base + x }; let answer = add(32);`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	answer, ok := interp.Get("answer")
+	if !ok {
+		t.Fatalf("answer not set")
+	}
+	testIntegerObject(t, answer, 42)
+
+	result, err := interp.Call("add", &object.Integer{Value: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testIntegerObject(t, result, 15)
+
+	result, err = interp.Call("len", &object.String{Value: "four"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testIntegerObject(t, result, 4)
+
+	if _, err := interp.Call("missing"); err == nil {
+		t.Errorf("expected an error calling an unknown function")
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	interp := NewInterpreter()
+
+	_, err := interp.Run(`let = 5;`)
+
+	var parseErr *parser.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a parse error. got=%v", err)
+	}
+
+	_, err = interp.Run(`1 + true`)
+
+	var objErr *object.Error
+	if !errors.As(err, &objErr) || objErr.Kind != object.RuntimeError {
+		t.Fatalf("expected a runtime error. got=%v", err)
+	}
+}
+
+func TestInterpreterLimits(t *testing.T) {
+	interp := NewInterpreter(WithLimits(Limits{MaxDepth: 50}))
+
+	_, err := interp.Run(`let f = fn(n) { f(n + 1) }; f(0)`)
+
+	var objErr *object.Error
+	if !errors.As(err, &objErr) || objErr.Kind != object.LimitError {
+		t.Fatalf("expected a limit error. got=%v", err)
+	}
+
+	if _, err := interp.Call("f", &object.Integer{Value: 0}); !errors.As(err, &objErr) || objErr.Kind != object.LimitError {
+		t.Fatalf("expected Call to be limited. got=%v", err)
+	}
+}
+
+func TestInterpretersAreIsolated(t *testing.T) {
+	a := NewInterpreter()
+	b := NewInterpreter()
+
+	if _, err := a.Run(`let x = 1;`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, ok := b.Get("x"); ok {
+		t.Errorf("binding leaked between interpreters")
+	}
+}
+
+func TestInterpretersRunConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	outputs := make([]bytes.Buffer, 8)
+
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			interp := NewInterpreter(WithStdout(&outputs[i]))
+			interp.Set("id", &object.Integer{Value: int64(i)})
+			_, err := interp.Run(`
+				let total = 0;
+				for (n in range(100)) { total = total + n }
+				puts(id, total)
+			`)
+			if err != nil {
+				t.Errorf("interpreter %d: %s", i, err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := range outputs {
+		want := fmt.Sprintf("%d\n4950\n", i)
+		if got := outputs[i].String(); got != want {
+			t.Errorf("interpreter %d printed %q, want %q", i, strings.TrimSpace(got), want)
+		}
+	}
+}
+
+func TestInterpreterRegisterFunc(t *testing.T) {
+	interp := NewInterpreter()
+
+	type user struct {
+		Name string `monkey:"name"`
+		Age  int    `monkey:"age"`
+	}
+
+	err := interp.RegisterFunc("lookup", func(name string) (*user, error) {
+		if name != "ada" {
+			return nil, fmt.Errorf("no user %q", name)
+		}
+		return &user{Name: "Ada", Age: 36}, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %s", err)
+	}
+
+	result, err := interp.Run(`let u = lookup("ada"); [u["name"], u["age"]]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result.Inspect() != "[Ada, 36]" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	if _, err := interp.Run(`lookup("bob")`); err == nil || err.Error() != `1:1: no user "bob"` {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	if err := interp.RegisterFunc("bad", "not a function"); err == nil {
+		t.Errorf("expected an error registering a non-function")
+	}
+}
+
+func TestInterpreterCallbacks(t *testing.T) {
+	interp := NewInterpreter()
+
+	var saved object.Object
+	interp.RegisterFunc("on_event", func(fn object.Object) { saved = fn })
+	interp.RegisterFunc("twice", func(ctx object.CallContext, fn object.Object, x int) object.Object {
+		return ctx.Call(fn, ctx.Call(fn, &object.Integer{Value: int64(x)}))
+	})
+
+	result, err := interp.Run(`on_event(fn(name) { "got " + name }); twice(fn(x) { x * 3 }, 2)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testIntegerObject(t, result, 18)
+
+	result, err = interp.Apply(saved, &object.String{Value: "click"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result.Inspect() != "got click" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	if _, err := interp.Apply(saved); err == nil {
+		t.Errorf("expected an arity error")
+	}
+}
+
+func TestInterpreterModules(t *testing.T) {
+	var stdout bytes.Buffer
+	interp := NewInterpreter(WithStdout(&stdout), WithResolver(object.NewFSResolver(fstest.MapFS{
+		"greet.mk": {Data: []byte(`puts("loading greet"); let hello = fn(name) { "hello " + name };`)},
+	})))
+
+	if _, err := interp.Run(`let g = import "greet"; puts(g.hello("you"))`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := interp.Run(`puts((import "greet").hello("again"))`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := "loading greet\nhello you\nhello again\n"; stdout.String() != want {
+		t.Errorf("stdout wrong. want=%q, got=%q", want, stdout.String())
+	}
+
+	if _, err := NewInterpreter().Run(`import "greet"`); err == nil {
+		t.Errorf("expected an error importing without a resolver")
+	}
+}
@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"gomonkey/ast"
+	"gomonkey/lexer"
+	"gomonkey/object"
+	"gomonkey/parser"
+)
+
+func evalImportExpression(node *ast.ImportExpression, env *object.Environment) object.Object {
+	modules := env.Modules()
+	if modules == nil {
+		return newError("cannot import %q: no module resolver", node.Path.Value)
+	}
+
+	module, err := modules.Load(node.Path.Value, func(name, src string) (*object.Module, error) {
+		return evalModule(name, src, modules, env.Budget())
+	})
+	if err != nil {
+		if errObj, ok := err.(*object.Error); ok {
+			return errObj
+		}
+
+		return newError("%s", err)
+	}
+
+	return module
+}
+
+// evalModule evaluates the source of a module in an environment of its
+// own and collects its exports. It runs under the importer's budget.
+func evalModule(name, src string, modules *object.Modules, budget *object.Budget) (*object.Module, error) {
+	p := parser.New(lexer.New(src, lexer.WithFilename(name)))
+	program := p.ParseProgram()
+
+	if parseErrors := p.ParseErrors(); len(parseErrors) != 0 {
+		return nil, &object.Error{Message: parseErrors[0].Msg, Pos: parseErrors[0].Pos}
+	}
+
+	env := object.NewEnvironment()
+	if scope := modules.Scope(); scope != nil {
+		env = object.NewEnclosedEnvironment(scope)
+	}
+	env.SetBudget(budget)
+	env.SetModules(modules)
+
+	if errObj, ok := Eval(program, env).(*object.Error); ok {
+		return nil, errObj
+	}
+
+	return object.NewModule(name, func(binding string) (object.Object, bool) {
+		if !env.Defines(binding) {
+			return nil, false
+		}
+
+		return env.Get(binding)
+	}), nil
+}
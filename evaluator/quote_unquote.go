@@ -0,0 +1,180 @@
+package evaluator
+
+import (
+	"fmt"
+	"gomonkey/ast"
+	"gomonkey/object"
+	"gomonkey/token"
+)
+
+func quote(node ast.Node, env *object.Environment) object.Object {
+	node, err := evalUnquoteCalls(node, env)
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+// evalUnquoteCalls replaces every unquote call in quoted with the node for
+// the value of its argument. It returns the first error an argument
+// evaluates to or that converting a value raises.
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var err *object.Error
+
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if err != nil || !isUnquoteCall(node) {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted.(*object.Error)
+			return node
+		}
+
+		converted, convertErr := convertObjectToASTNode(unquoted, make(map[object.Object]bool))
+		if convertErr != nil {
+			err = convertErr
+			return node
+		}
+
+		return converted
+	})
+
+	return node, err
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	callExpression, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	return callExpression.Function.TokenLiteral() == "unquote"
+}
+
+// convertObjectToASTNode returns the literal that evaluates to obj. Arrays
+// and hashes become literals of their converted elements; seen holds the
+// ones being converted, so that one that contains itself is an error, as
+// is a value no literal can express, such as a function.
+func convertObjectToASTNode(obj object.Object, seen map[object.Object]bool) (ast.Node, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: fmt.Sprintf("%d", obj.Value),
+		}
+
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, nil
+	case *object.BigInt:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: obj.Inspect(),
+		}
+
+		return &ast.BigIntLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Float:
+		t := token.Token{
+			Type:    token.FLOAT,
+			Literal: obj.Inspect(),
+		}
+
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}, nil
+	case *object.String:
+		t := token.Token{
+			Type:    token.STRING,
+			Literal: obj.Value,
+		}
+
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Boolean:
+		var t token.Token
+
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+
+		return &ast.Boolean{Token: t, Value: obj.Value}, nil
+	case *object.Array:
+		if seen[obj] {
+			return nil, newError("cannot unquote ARRAY that contains itself")
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		literal := &ast.ArrayLiteral{
+			Token:  token.Token{Type: token.LBRACKET, Literal: "["},
+			Rbrack: token.Token{Type: token.RBRACKET, Literal: "]"},
+		}
+
+		for _, element := range obj.Elements {
+			expression, err := convertObjectToExpression(element, seen)
+			if err != nil {
+				return nil, err
+			}
+
+			literal.Elements = append(literal.Elements, expression)
+		}
+
+		return literal, nil
+	case *object.Hash:
+		if seen[obj] {
+			return nil, newError("cannot unquote HASH that contains itself")
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		literal := &ast.HashLiteral{
+			Token:  token.Token{Type: token.LBRACE, Literal: "{"},
+			Rbrace: token.Token{Type: token.RBRACE, Literal: "}"},
+		}
+
+		for _, pair := range obj.Ordered() {
+			key, err := convertObjectToExpression(pair.Key, seen)
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := convertObjectToExpression(pair.Value, seen)
+			if err != nil {
+				return nil, err
+			}
+
+			literal.Pairs = append(literal.Pairs, ast.HashPair{Key: key, Value: value})
+		}
+
+		return literal, nil
+	case *object.Quote:
+		return obj.Node, nil
+	default:
+		return nil, newError("cannot unquote %s", obj.Type())
+	}
+}
+
+// convertObjectToExpression converts an element of an array or hash, which
+// has to be an expression.
+func convertObjectToExpression(obj object.Object, seen map[object.Object]bool) (ast.Expression, *object.Error) {
+	node, err := convertObjectToASTNode(obj, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	expression, ok := node.(ast.Expression)
+	if !ok {
+		return nil, newError("cannot unquote %s", obj.Type())
+	}
+
+	return expression, nil
+}
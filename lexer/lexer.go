@@ -1,44 +1,114 @@
 package lexer
 
-import "gomonkey/token"
+import (
+	"gomonkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	input        string
+	filename     string
+	comments     bool
 	position     int
 	readPosition int
-	ch           byte
+	line         int
+	column       int
+	ch           rune
 }
 
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+type Option func(*Lexer)
+
+// WithFilename records name as the source file of every token position.
+func WithFilename(name string) Option {
+	return func(l *Lexer) {
+		l.filename = name
+	}
+}
+
+// WithComments makes NextToken return comments as COMMENT tokens instead
+// of skipping them.
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.comments = true
+	}
+}
+
+func New(input string, opts ...Option) *Lexer {
+	l := &Lexer{input: input, line: 1}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
 	l.readChar()
 
 	return l
 }
 
+// readChar decodes the next UTF-8 encoded rune. Columns count runes, while
+// positions and offsets stay byte-based so they can slice the input.
+// Once the end of the input is reached the position stops moving, so every
+// EOF token is found at the same place.
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		return
+	}
+
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
+	l.column += 1
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	for {
+		l.skipWhitespace()
 
-	l.skipWhitespace()
+		pos := l.pos()
+		tok := l.readToken()
+		tok.Pos = pos
+		tok.End = l.pos()
+
+		if tok.Type != token.COMMENT || l.comments {
+			return tok
+		}
+	}
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -50,9 +120,9 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newTokenFromChar(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newTokenFromChar(token.PLUS, l.ch)
+		tok = l.readOperator(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newTokenFromChar(token.MINUS, l.ch)
+		tok = l.readOperator(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -62,17 +132,49 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newTokenFromChar(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newTokenFromChar(token.SLASH, l.ch)
+		switch l.peekChar() {
+		case '/':
+			tok = l.readLineComment()
+		case '*':
+			tok = l.readBlockComment()
+		default:
+			tok = l.readOperator(token.SLASH, token.SLASH_ASSIGN)
+		}
 	case '*':
-		tok = newTokenFromChar(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			l.readChar()
+			tok = newToken(token.POWER, "**")
+		} else {
+			tok = l.readOperator(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
+	case '%':
+		tok = newTokenFromChar(token.PERCENT, l.ch)
 	case '<':
-		tok = newTokenFromChar(token.LT, l.ch)
+		tok = l.readComparison(token.LT, token.LT_EQ, token.SHIFT_LEFT)
 	case '>':
-		tok = newTokenFromChar(token.GT, l.ch)
+		tok = l.readComparison(token.GT, token.GT_EQ, token.SHIFT_RIGHT)
+	case '^':
+		tok = newTokenFromChar(token.BIT_XOR, l.ch)
+	case '~':
+		tok = newTokenFromChar(token.BIT_NOT, l.ch)
+	case '&':
+		tok = l.readPair(token.BIT_AND, token.AND)
+	case '|':
+		tok = l.readPair(token.BIT_OR, token.OR)
 	case ';':
 		tok = newTokenFromChar(token.SEMICOLON, l.ch)
 	case ',':
 		tok = newTokenFromChar(token.COMMA, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = newToken(token.ELLIPSIS, "...")
+		} else {
+			tok = newTokenFromChar(token.DOT, l.ch)
+		}
+	case ':':
+		tok = newTokenFromChar(token.COLON, l.ch)
 	case '(':
 		tok = newTokenFromChar(token.LPAREN, l.ch)
 	case ')':
@@ -86,7 +188,9 @@ func (l *Lexer) NextToken() token.Token {
 	case ']':
 		tok = newTokenFromChar(token.RBRACKET, l.ch)
 	case '"':
-		tok = newToken(token.STRING, l.readString())
+		tok = l.readString()
+	case '`':
+		tok = l.readRawString()
 	case 0:
 		tok = newToken(token.EOF, "")
 	default:
@@ -95,7 +199,8 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.LookupIdent(literal), literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok = newToken(token.INT, l.readNumber())
+			tokenType, literal := l.readNumber()
+			tok = newToken(tokenType, literal)
 			return tok
 		} else {
 			tok = newTokenFromChar(token.ILLEGAL, l.ch)
@@ -107,27 +212,222 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readOperator reads a single-character operator, or the two-character
+// form given by assign when the operator is followed by '='.
+func (l *Lexer) readOperator(single, assign token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return newToken(assign, string(ch)+string(l.ch))
+	}
+
+	return newTokenFromChar(single, l.ch)
+}
+
+// readPair reads a single-character operator, or the operator given by
+// pair when the character is doubled, as in "&&".
+func (l *Lexer) readPair(single, pair token.TokenType) token.Token {
+	if l.peekChar() != l.ch {
+		return newTokenFromChar(single, l.ch)
+	}
+
+	ch := l.ch
+	l.readChar()
+
+	return newToken(pair, string(ch)+string(l.ch))
+}
+
+// readComparison reads '<' or '>', optionally followed by '=' or by a
+// second '<' or '>' making it a shift.
+func (l *Lexer) readComparison(single, orEqual, shift token.TokenType) token.Token {
+	if l.peekChar() == l.ch {
+		return l.readPair(single, shift)
+	}
+
+	return l.readOperator(single, orEqual)
+}
+
+// readString reads a double-quoted string and decodes its escape
+// sequences. A string that is never closed, or that contains an invalid
+// escape, is returned as an ILLEGAL token holding the offending source.
+func (l *Lexer) readString() token.Token {
+	start := l.position
+	invalid := ""
+
+	var out strings.Builder
+
 	for {
 		l.readChar()
 
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case 0:
+			return newToken(token.ILLEGAL, l.input[start:l.position])
+		case '"':
+			if invalid != "" {
+				return newToken(token.ILLEGAL, invalid)
+			}
+			return newToken(token.STRING, out.String())
+		case '\\':
+			escape := l.position
+			l.readChar()
+
+			r, ok := l.readEscape()
+			if l.ch == 0 {
+				return newToken(token.ILLEGAL, l.input[start:l.position])
+			}
+			if !ok && invalid == "" {
+				invalid = l.input[escape:l.readPosition]
+			}
+
+			out.WriteRune(r)
+		default:
+			out.WriteRune(l.ch)
 		}
 	}
+}
 
-	return l.input[position:l.position]
+// readEscape decodes the escape sequence whose first character, after the
+// backslash, is the current one. It leaves the lexer on the sequence's last
+// character.
+func (l *Lexer) readEscape() (rune, bool) {
+	switch l.ch {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	case '"':
+		return '"', true
+	case '\\':
+		return '\\', true
+	case 'u':
+		if l.peekChar() != '{' {
+			return 0, false
+		}
+		l.readChar()
+
+		digits := l.position + 1
+		for isHexDigit(l.peekChar()) {
+			l.readChar()
+		}
+		hex := l.input[digits:l.readPosition]
+
+		if l.peekChar() != '}' {
+			return 0, false
+		}
+		l.readChar()
+
+		value, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) > 6 || !utf8.ValidRune(rune(value)) {
+			return 0, false
+		}
+
+		return rune(value), true
+	default:
+		return 0, false
+	}
 }
 
-func (l *Lexer) readNumber() string {
+// readRawString reads a backtick-quoted string verbatim. Raw strings may
+// span several lines and have no escape sequences.
+func (l *Lexer) readRawString() token.Token {
+	start := l.position
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case 0:
+			return newToken(token.ILLEGAL, l.input[start:l.position])
+		case '`':
+			return newToken(token.STRING, l.input[start+1:l.position])
+		}
+	}
+}
+
+// readLineComment reads a // comment up to, but not including, the end of
+// the line.
+func (l *Lexer) readLineComment() token.Token {
+	start := l.position
+
+	for l.peekChar() != '\n' && l.peekChar() != 0 {
+		l.readChar()
+	}
+
+	return newToken(token.COMMENT, l.input[start:l.readPosition])
+}
+
+// readBlockComment reads a /* */ comment, which may span several lines. A
+// comment that is never closed is returned as an ILLEGAL token.
+func (l *Lexer) readBlockComment() token.Token {
+	start := l.position
+	l.readChar()
+
+	for {
+		l.readChar()
+
+		switch {
+		case l.ch == 0:
+			return newToken(token.ILLEGAL, l.input[start:l.position])
+		case l.ch == '*' && l.peekChar() == '/':
+			l.readChar()
+			return newToken(token.COMMENT, l.input[start:l.readPosition])
+		}
+	}
+}
+
+// readNumber reads an integer or a float such as 0.5, 2e10 or 1.5E-3.
+// Integers may also be written as 0x1F, 0o17 or 0b1010, and digits may be
+// separated by underscores as in 1_000_000. A dot or exponent marker only
+// belongs to the number when a digit follows. The parser validates the
+// literal, so malformed digits and separators are reported there.
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
 
-	for isDigit(l.ch) {
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
 		l.readChar()
+		l.readChar()
+
+		for isHexDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+
+		return tokenType, l.input[position:l.position]
 	}
 
-	return l.input[position:l.position]
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if (next == '+' || next == '-') && l.readPosition+1 < len(l.input) {
+			next = rune(l.input[l.readPosition+1])
+		}
+
+		if isDigit(next) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return tokenType, l.input[position:l.position]
+}
+
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -146,18 +446,32 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func isBasePrefix(ch rune) bool {
+	switch ch {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	default:
+		return false
+	}
+}
+
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
 func newToken(tokenType token.TokenType, literal string) token.Token {
 	return token.Token{Type: tokenType, Literal: literal}
 }
 
-func newTokenFromChar(tokenType token.TokenType, ch byte) token.Token {
+func newTokenFromChar(tokenType token.TokenType, ch rune) token.Token {
 	return newToken(tokenType, string(ch))
 }
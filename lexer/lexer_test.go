@@ -15,7 +15,7 @@ func TestNextToken(t *testing.T) {
     };
 
     let result = add(five, ten);
-    !-/*5;
+    !-/ *5;
     5 < 10 > 5;
 
     if (5 < 10) {
@@ -31,6 +31,8 @@ func TestNextToken(t *testing.T) {
     "foo bar";
 
     [1, 2];
+    {"foo": "bar"}
+    macro(x, y) { x + y; };
    `
 
 	tests := []struct {
@@ -120,6 +122,24 @@ func TestNextToken(t *testing.T) {
 		{token.INT, "2"},
 		{token.RBRACKET, "]"},
 		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 
@@ -137,3 +157,423 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestNumbers(t *testing.T) {
+	input := "5 0.5 12.25 1e-3 2E10 6.02e+23 1.foo 3e x 0x1F 0XfF 0o17 0b1010 1_000_000 1_000.5 0b102 0x"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "12.25"},
+		{token.FLOAT, "1e-3"},
+		{token.FLOAT, "2E10"},
+		{token.FLOAT, "6.02e+23"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENT, "foo"},
+		{token.INT, "3"},
+		{token.IDENT, "e"},
+		{token.IDENT, "x"},
+		{token.INT, "0x1F"},
+		{token.INT, "0XfF"},
+		{token.INT, "0o17"},
+		{token.INT, "0b1010"},
+		{token.INT, "1_000_000"},
+		{token.FLOAT, "1_000.5"},
+		{token.INT, "0b102"},
+		{token.INT, "0x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := "x = 1; x += 2; x -= 3; x *= 4; x /= 5; x == y; a+b"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "x"}, {token.ASSIGN, "="}, {token.INT, "1"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.PLUS_ASSIGN, "+="}, {token.INT, "2"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.MINUS_ASSIGN, "-="}, {token.INT, "3"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.ASTERISK_ASSIGN, "*="}, {token.INT, "4"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.SLASH_ASSIGN, "/="}, {token.INT, "5"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "x"}, {token.EQ, "=="}, {token.IDENT, "y"}, {token.SEMICOLON, ";"},
+		{token.IDENT, "a"}, {token.PLUS, "+"}, {token.IDENT, "b"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestArithmeticAndComparisonOperators(t *testing.T) {
+	input := "a % b ** c *= d <= e >= f < g"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "b"},
+		{token.POWER, "**"},
+		{token.IDENT, "c"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.IDENT, "d"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "e"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "f"},
+		{token.LT, "<"},
+		{token.IDENT, "g"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	input := "~a ^ b << 2 >> c <= d >= e"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.BIT_NOT, "~"},
+		{token.IDENT, "a"},
+		{token.BIT_XOR, "^"},
+		{token.IDENT, "b"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INT, "2"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.IDENT, "c"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "d"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "e"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestEllipsis(t *testing.T) {
+	input := "fn(...rest) { f(...rest, ..) }"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.COMMA, ","},
+		{token.DOT, "."},
+		{token.DOT, "."},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	input := "a && b || c & d | e"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.BIT_AND, "&"},
+		{token.IDENT, "d"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "e"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"plain"`, token.STRING, "plain"},
+		{`"a\nb\tc"`, token.STRING, "a\nb\tc"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"\u{48}\u{1F600}"`, token.STRING, "H\U0001F600"},
+		{"`raw \\n\nstring`", token.STRING, "raw \\n\nstring"},
+		{`"never closed`, token.ILLEGAL, `"never closed`},
+		{"`never closed", token.ILLEGAL, "`never closed"},
+		{`"bad \q escape"`, token.ILLEGAL, `\q`},
+		{`"\u{110000}"`, token.ILLEGAL, `\u{110000}`},
+		{`"\u{41"`, token.ILLEGAL, `\u{41`},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - wrong token for %s. expected=%s %q, got=%s %q",
+				i, tt.input, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestRawStringPositions(t *testing.T) {
+	l := New("`a\nb` x")
+
+	str := l.NextToken()
+	if str.End.Line != 2 || str.End.Column != 3 {
+		t.Errorf("wrong raw string end. got=%d:%d", str.End.Line, str.End.Column)
+	}
+
+	ident := l.NextToken()
+	if ident.Pos.Line != 2 || ident.Pos.Column != 4 {
+		t.Errorf("wrong position after raw string. got=%d:%d", ident.Pos.Line, ident.Pos.Column)
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let preço = \"héllo 🙈\"; naïve + π"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedOffset  int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 0, 1},
+		{token.IDENT, "preço", 4, 5},
+		{token.ASSIGN, "=", 11, 11},
+		{token.STRING, "héllo 🙈", 13, 13},
+		{token.SEMICOLON, ";", 26, 22},
+		{token.IDENT, "naïve", 28, 24},
+		{token.PLUS, "+", 35, 30},
+		{token.IDENT, "π", 37, 32},
+		{token.EOF, "", 39, 33},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Offset != tt.expectedOffset || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - wrong position for %q. expected offset=%d col=%d, got offset=%d col=%d",
+				i, tok.Literal, tt.expectedOffset, tt.expectedColumn, tok.Pos.Offset, tok.Pos.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// leading\nlet x = 1 / 2; /* block\ncomment */ x // trailing"
+
+	skipped := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.INT, token.SLASH, token.INT,
+		token.SEMICOLON, token.IDENT, token.EOF,
+	}
+
+	l := New(input)
+	for i, expected := range skipped {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("skipped[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+
+	emitted := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// leading"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "/* block\ncomment */"},
+		{token.IDENT, "x"},
+		{token.COMMENT, "// trailing"},
+		{token.EOF, ""},
+	}
+
+	l = New(input, WithComments())
+	for i, tt := range emitted {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("emitted[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	tok := New("1 /* never closed").NextToken()
+	if tok.Type != token.INT {
+		t.Fatalf("expected INT first. got=%q", tok.Type)
+	}
+
+	l := New("x /* never closed")
+	l.NextToken()
+
+	tok = l.NextToken()
+	if tok.Type != token.ILLEGAL || tok.Literal != "/* never closed" {
+		t.Errorf("wrong token. expected=ILLEGAL %q, got=%s %q", "/* never closed", tok.Type, tok.Literal)
+	}
+	if tok.Pos.Column != 3 {
+		t.Errorf("wrong column. expected=3, got=%d", tok.Pos.Column)
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  foo(\"bar\")\n"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedPos    token.Position
+		expectedEndCol int
+	}{
+		{token.LET, token.Position{Filename: "main.mk", Offset: 0, Line: 1, Column: 1}, 4},
+		{token.IDENT, token.Position{Filename: "main.mk", Offset: 4, Line: 1, Column: 5}, 6},
+		{token.ASSIGN, token.Position{Filename: "main.mk", Offset: 6, Line: 1, Column: 7}, 8},
+		{token.INT, token.Position{Filename: "main.mk", Offset: 8, Line: 1, Column: 9}, 10},
+		{token.SEMICOLON, token.Position{Filename: "main.mk", Offset: 9, Line: 1, Column: 10}, 11},
+		{token.IDENT, token.Position{Filename: "main.mk", Offset: 13, Line: 2, Column: 3}, 6},
+		{token.LPAREN, token.Position{Filename: "main.mk", Offset: 16, Line: 2, Column: 6}, 7},
+		{token.STRING, token.Position{Filename: "main.mk", Offset: 17, Line: 2, Column: 7}, 12},
+		{token.RPAREN, token.Position{Filename: "main.mk", Offset: 22, Line: 2, Column: 12}, 13},
+		{token.EOF, token.Position{Filename: "main.mk", Offset: 24, Line: 3, Column: 1}, 1},
+		{token.EOF, token.Position{Filename: "main.mk", Offset: 24, Line: 3, Column: 1}, 1},
+	}
+
+	l := New(input, WithFilename("main.mk"))
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - position wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+
+		if tok.End.Column != tt.expectedEndCol {
+			t.Errorf("tests[%d] - end column wrong. expected=%d, got=%d", i, tt.expectedEndCol, tok.End.Column)
+		}
+	}
+}
+
+func TestImportAndMemberAccess(t *testing.T) {
+	input := `let m = import "lib/math"; m.pi + 1.5`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "m"},
+		{token.ASSIGN, "="},
+		{token.IMPORT, "import"},
+		{token.STRING, "lib/math"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "m"},
+		{token.DOT, "."},
+		{token.IDENT, "pi"},
+		{token.PLUS, "+"},
+		{token.FLOAT, "1.5"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
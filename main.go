@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"gomonkey/repl"
 	"os"
@@ -8,6 +9,9 @@ import (
 )
 
 func main() {
+	engine := flag.String("engine", repl.EngineEval, "use 'eval' or 'vm'")
+	flag.Parse()
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -15,5 +19,5 @@ func main() {
 
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Println("Feel free to type in commands")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, *engine)
 }
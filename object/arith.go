@@ -0,0 +1,273 @@
+package object
+
+import (
+	"gomonkey/token"
+	"math"
+	"math/big"
+)
+
+// MaxIntegerBits bounds the size of the integers a left shift may produce,
+// so that `1 << 100000000000` fails instead of exhausting memory.
+const MaxIntegerBits = 1 << 20
+
+// IsNumber reports whether obj is an INTEGER, BIG_INTEGER or FLOAT.
+func IsNumber(obj Object) bool {
+	return isInteger(obj) || obj.Type() == FLOAT_OBJ
+}
+
+func isInteger(obj Object) bool {
+	return obj.Type() == INTEGER_OBJ || obj.Type() == BIG_INTEGER_OBJ
+}
+
+// NumberInfix applies an arithmetic, bitwise or comparison operator to two
+// numbers. It is shared by the evaluator and the VM so that both compute
+// the same results. Integer arithmetic that overflows an int64 is redone on
+// big integers, and a float on either side makes the operation a float one.
+func NumberInfix(operator token.TokenType, left, right Object) Object {
+	switch {
+	case left.Type() == INTEGER_OBJ && right.Type() == INTEGER_OBJ:
+		return integerInfix(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return bigIntInfix(operator, left, right)
+	default:
+		return floatInfix(operator, left, right)
+	}
+}
+
+func integerInfix(operator token.TokenType, left, right Object) Object {
+	leftValue := left.(*Integer).Value
+	rightValue := right.(*Integer).Value
+
+	switch operator {
+	case token.PLUS:
+		if result, ok := addInt64(leftValue, rightValue); ok {
+			return &Integer{Value: result}
+		}
+		return bigIntInfix(operator, left, right)
+	case token.MINUS:
+		if result, ok := subInt64(leftValue, rightValue); ok {
+			return &Integer{Value: result}
+		}
+		return bigIntInfix(operator, left, right)
+	case token.ASTERISK:
+		if result, ok := mulInt64(leftValue, rightValue); ok {
+			return &Integer{Value: result}
+		}
+		return bigIntInfix(operator, left, right)
+	case token.SLASH:
+		if rightValue == 0 {
+			return newError("division by zero")
+		}
+		if leftValue == math.MinInt64 && rightValue == -1 {
+			return bigIntInfix(operator, left, right)
+		}
+		return &Integer{Value: leftValue / rightValue}
+	case token.PERCENT:
+		if rightValue == 0 {
+			return newError("modulo by zero")
+		}
+		return &Integer{Value: leftValue % rightValue}
+	case token.POWER:
+		if rightValue < 0 {
+			return floatInfix(operator, left, right)
+		}
+		if result, ok := powInt64(leftValue, rightValue); ok {
+			return &Integer{Value: result}
+		}
+		return bigIntInfix(operator, left, right)
+	case token.BIT_AND:
+		return &Integer{Value: leftValue & rightValue}
+	case token.BIT_OR:
+		return &Integer{Value: leftValue | rightValue}
+	case token.BIT_XOR:
+		return &Integer{Value: leftValue ^ rightValue}
+	case token.SHIFT_LEFT:
+		if rightValue < 0 {
+			return newError("negative shift count: %d", rightValue)
+		}
+		if result, ok := shlInt64(leftValue, rightValue); ok {
+			return &Integer{Value: result}
+		}
+		return bigIntInfix(operator, left, right)
+	case token.SHIFT_RIGHT:
+		if rightValue < 0 {
+			return newError("negative shift count: %d", rightValue)
+		}
+		return &Integer{Value: leftValue >> uint64(rightValue)}
+	case token.LT:
+		return NativeBool(leftValue < rightValue)
+	case token.GT:
+		return NativeBool(leftValue > rightValue)
+	case token.LT_EQ:
+		return NativeBool(leftValue <= rightValue)
+	case token.GT_EQ:
+		return NativeBool(leftValue >= rightValue)
+	case token.EQ:
+		return NativeBool(leftValue == rightValue)
+	case token.NOT_EQ:
+		return NativeBool(leftValue != rightValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// bigIntInfix handles integer arithmetic that does not fit in an int64.
+// Results are demoted back to an Integer when they fit.
+func bigIntInfix(operator token.TokenType, left, right Object) Object {
+	leftValue, _ := BigValue(left)
+	rightValue, _ := BigValue(right)
+
+	switch operator {
+	case token.PLUS:
+		return NewInteger(new(big.Int).Add(leftValue, rightValue))
+	case token.MINUS:
+		return NewInteger(new(big.Int).Sub(leftValue, rightValue))
+	case token.ASTERISK:
+		return NewInteger(new(big.Int).Mul(leftValue, rightValue))
+	case token.SLASH:
+		if rightValue.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return NewInteger(new(big.Int).Quo(leftValue, rightValue))
+	case token.PERCENT:
+		if rightValue.Sign() == 0 {
+			return newError("modulo by zero")
+		}
+		return NewInteger(new(big.Int).Rem(leftValue, rightValue))
+	case token.POWER:
+		if rightValue.Sign() < 0 {
+			return floatInfix(operator, left, right)
+		}
+		return NewInteger(new(big.Int).Exp(leftValue, rightValue, nil))
+	case token.BIT_AND:
+		return NewInteger(new(big.Int).And(leftValue, rightValue))
+	case token.BIT_OR:
+		return NewInteger(new(big.Int).Or(leftValue, rightValue))
+	case token.BIT_XOR:
+		return NewInteger(new(big.Int).Xor(leftValue, rightValue))
+	case token.SHIFT_LEFT, token.SHIFT_RIGHT:
+		if rightValue.Sign() < 0 {
+			return newError("negative shift count: %s", rightValue)
+		}
+		if !rightValue.IsInt64() {
+			return newError("shift count too large: %s", rightValue)
+		}
+		n := rightValue.Int64()
+		if operator == token.SHIFT_RIGHT {
+			return NewInteger(new(big.Int).Rsh(leftValue, uint(n)))
+		}
+		if leftValue.Sign() != 0 && int64(leftValue.BitLen())+n > MaxIntegerBits {
+			return newError("shift result too large: more than %d bits", MaxIntegerBits)
+		}
+		return NewInteger(new(big.Int).Lsh(leftValue, uint(n)))
+	case token.LT:
+		return NativeBool(leftValue.Cmp(rightValue) < 0)
+	case token.GT:
+		return NativeBool(leftValue.Cmp(rightValue) > 0)
+	case token.LT_EQ:
+		return NativeBool(leftValue.Cmp(rightValue) <= 0)
+	case token.GT_EQ:
+		return NativeBool(leftValue.Cmp(rightValue) >= 0)
+	case token.EQ:
+		return NativeBool(leftValue.Cmp(rightValue) == 0)
+	case token.NOT_EQ:
+		return NativeBool(leftValue.Cmp(rightValue) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// floatInfix handles arithmetic where at least one operand is a float; an
+// integer on the other side is promoted.
+func floatInfix(operator token.TokenType, left, right Object) Object {
+	leftValue, _ := floatValue(left)
+	rightValue, _ := floatValue(right)
+
+	switch operator {
+	case token.PLUS:
+		return &Float{Value: leftValue + rightValue}
+	case token.MINUS:
+		return &Float{Value: leftValue - rightValue}
+	case token.ASTERISK:
+		return &Float{Value: leftValue * rightValue}
+	case token.SLASH:
+		return &Float{Value: leftValue / rightValue}
+	case token.PERCENT:
+		return &Float{Value: math.Mod(leftValue, rightValue)}
+	case token.POWER:
+		return &Float{Value: math.Pow(leftValue, rightValue)}
+	case token.LT:
+		return NativeBool(leftValue < rightValue)
+	case token.GT:
+		return NativeBool(leftValue > rightValue)
+	case token.LT_EQ:
+		return NativeBool(leftValue <= rightValue)
+	case token.GT_EQ:
+		return NativeBool(leftValue >= rightValue)
+	case token.EQ:
+		return NativeBool(leftValue == rightValue)
+	case token.NOT_EQ:
+		return NativeBool(leftValue != rightValue)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// addInt64, subInt64 and mulInt64 report false when the result
+// overflows an int64.
+func addInt64(a, b int64) (int64, bool) {
+	c := a + b
+	return c, (c > a) == (b > 0)
+}
+
+func subInt64(a, b int64) (int64, bool) {
+	c := a - b
+	return c, (c < a) == (b > 0)
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return c, false
+	}
+	return c, true
+}
+
+// powInt64 computes a**b for b >= 0 by repeated squaring and reports false
+// when the result overflows an int64.
+func powInt64(a, b int64) (int64, bool) {
+	result := int64(1)
+
+	for b > 0 {
+		var ok bool
+
+		if b&1 == 1 {
+			if result, ok = mulInt64(result, a); !ok {
+				return 0, false
+			}
+		}
+
+		b >>= 1
+		if b > 0 {
+			if a, ok = mulInt64(a, a); !ok {
+				return 0, false
+			}
+		}
+	}
+
+	return result, true
+}
+
+// shlInt64 computes a<<n for n >= 0 and reports false when bits would be
+// shifted out of an int64.
+func shlInt64(a, n int64) (int64, bool) {
+	if n >= 64 {
+		return 0, a == 0
+	}
+
+	c := a << uint64(n)
+	return c, c>>uint64(n) == a
+}
@@ -0,0 +1,51 @@
+package object
+
+import (
+	"hash/fnv"
+	"math/big"
+)
+
+// BigInt is an integer that does not fit in 64 bits. Arithmetic promotes
+// an Integer to a BigInt on overflow and NewInteger demotes results that
+// fit again, so a value is only ever represented one way.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Type() ObjectType { return BIG_INTEGER_OBJ }
+func (b *BigInt) Inspect() string  { return b.Value.String() }
+
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(b.Value.Bytes())
+
+	value := h.Sum64()
+	if b.Value.Sign() < 0 {
+		value = ^value
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+// NewInteger returns v as an *Integer when it fits in an int64 and as a
+// *BigInt otherwise.
+func NewInteger(v *big.Int) Object {
+	if v.IsInt64() {
+		return &Integer{Value: v.Int64()}
+	}
+
+	return &BigInt{Value: v}
+}
+
+// BigValue returns the value of an INTEGER or BIG_INTEGER object as a
+// big.Int. The result must not be modified.
+func BigValue(obj Object) (*big.Int, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value), true
+	case *BigInt:
+		return obj.Value, true
+	default:
+		return nil, false
+	}
+}
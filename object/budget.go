@@ -0,0 +1,77 @@
+package object
+
+import (
+	"context"
+	"fmt"
+)
+
+// cancelCheckInterval is how many steps a Budget takes between polls of
+// its context, so that checking for cancellation stays cheap.
+const cancelCheckInterval = 1024
+
+// Budget bounds the work one evaluation may do: the number of steps, the
+// depth of nested function calls and the lifetime of a context. A zero
+// maximum means no limit, and a nil *Budget allows everything.
+type Budget struct {
+	ctx      context.Context
+	maxSteps int64
+	steps    int64
+	maxDepth int
+	depth    int
+}
+
+func NewBudget(ctx context.Context, maxSteps int64, maxDepth int) *Budget {
+	return &Budget{ctx: ctx, maxSteps: maxSteps, maxDepth: maxDepth}
+}
+
+// Step accounts for one unit of work. It returns a LimitError once the
+// steps run out or the context is done.
+func (b *Budget) Step() *Error {
+	if b == nil {
+		return nil
+	}
+
+	b.steps++
+	if b.maxSteps > 0 && b.steps > b.maxSteps {
+		return limitError("step limit of %d exceeded", b.maxSteps)
+	}
+
+	if b.steps%cancelCheckInterval == 0 {
+		return b.checkContext()
+	}
+
+	return nil
+}
+
+// Enter accounts for a function call, which must be matched by Leave when
+// it returns.
+func (b *Budget) Enter() *Error {
+	if b == nil {
+		return nil
+	}
+
+	if b.maxDepth > 0 && b.depth >= b.maxDepth {
+		return limitError("call depth limit of %d exceeded", b.maxDepth)
+	}
+	b.depth++
+
+	return b.checkContext()
+}
+
+func (b *Budget) Leave() {
+	if b != nil {
+		b.depth--
+	}
+}
+
+func (b *Budget) checkContext() *Error {
+	if err := b.ctx.Err(); err != nil {
+		return limitError("evaluation stopped: %s", err)
+	}
+
+	return nil
+}
+
+func limitError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: LimitError}
+}
@@ -0,0 +1,661 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"len",
+		&Builtin{
+			Name: "len",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+				}
+
+				switch arg := args[0].(type) {
+				case *String:
+					return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+				case *Array:
+					return &Integer{Value: int64(len(arg.Elements))}
+				case *Hash:
+					return &Integer{Value: int64(len(arg.Pairs))}
+				case *Range:
+					return &Integer{Value: arg.Len()}
+				default:
+					return newError("`len` builtin function doesn't support argument of type %s", arg.Type())
+				}
+			},
+		},
+	},
+	{
+		"first",
+		&Builtin{
+			Name: "first",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+				}
+
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*Array)
+				if len(arr.Elements) > 0 {
+					return arr.Elements[0]
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"last",
+		&Builtin{
+			Name: "last",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+				}
+
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*Array)
+				if length := len(arr.Elements); length > 0 {
+					return arr.Elements[length-1]
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"rest",
+		&Builtin{
+			Name: "rest",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+				}
+
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+				}
+
+				arr := args[0].(*Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					newElements := make([]Object, length-1)
+					copy(newElements, arr.Elements[1:length])
+					return &Array{Elements: newElements}
+				}
+
+				return nil
+			},
+		},
+	},
+	{
+		"push",
+		&Builtin{
+			Name: "push",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ {
+					return newError("argument to `push` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				arr := args[0].(*Array)
+				length := len(arr.Elements)
+				newElements := make([]Object, length+1)
+				copy(newElements, arr.Elements)
+				newElements[length] = args[1]
+				return &Array{Elements: newElements}
+			},
+		},
+	},
+	{
+		"keys",
+		&Builtin{
+			Name: "keys",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+				}
+
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+				}
+
+				pairs := args[0].(*Hash).Ordered()
+				elements := make([]Object, len(pairs))
+				for i, pair := range pairs {
+					elements[i] = pair.Key
+				}
+
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		"values",
+		&Builtin{
+			Name: "values",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+				}
+
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `values` must be HASH, got %s", args[0].Type())
+				}
+
+				pairs := args[0].(*Hash).Ordered()
+				elements := make([]Object, len(pairs))
+				for i, pair := range pairs {
+					elements[i] = pair.Value
+				}
+
+				return &Array{Elements: elements}
+			},
+		},
+	},
+	{
+		"has",
+		&Builtin{
+			Name: "has",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", lenArgs)
+				}
+
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `has` must be HASH, got %s", args[0].Type())
+				}
+
+				key, ok := args[1].(Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				_, ok = args[0].(*Hash).Get(key.HashKey())
+
+				return NativeBool(ok)
+			},
+		},
+	},
+	{
+		"put",
+		&Builtin{
+			Name: "put",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 3 {
+					return newError("wrong number of arguments. got=%d, want=3", lenArgs)
+				}
+
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `put` must be HASH, got %s", args[0].Type())
+				}
+
+				key, ok := args[1].(Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				hash := args[0].(*Hash).Copy()
+				hash.Set(key.HashKey(), HashPair{Key: args[1], Value: args[2]})
+
+				return hash
+			},
+		},
+	},
+	{
+		"delete",
+		&Builtin{
+			Name: "delete",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", lenArgs)
+				}
+
+				if args[0].Type() != HASH_OBJ {
+					return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+				}
+
+				key, ok := args[1].(Hashable)
+				if !ok {
+					return newError("unusable as hash key: %s", args[1].Type())
+				}
+
+				hash := args[0].(*Hash).Copy()
+				hash.Delete(key.HashKey())
+
+				return hash
+			},
+		},
+	},
+	{
+		"exit",
+		&Builtin{
+			Name: "exit",
+			Fn: ExitBuiltin(func(code int) Object {
+				os.Exit(code)
+				return nil
+			}),
+		},
+	},
+	{
+		"int",
+		&Builtin{
+			Name: "int",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer, *BigInt:
+					return arg
+				case *Float:
+					if math.IsInf(arg.Value, 0) || math.IsNaN(arg.Value) {
+						return newError("could not convert %s to INTEGER", arg.Inspect())
+					}
+					value, _ := big.NewFloat(arg.Value).Int(nil)
+					return NewInteger(value)
+				case *String:
+					value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 0)
+					if !ok {
+						return newError("could not convert %q to INTEGER", arg.Value)
+					}
+					return NewInteger(value)
+				default:
+					return newError("`int` builtin function doesn't support argument of type %s", arg.Type())
+				}
+			},
+		},
+	},
+	{
+		"float",
+		&Builtin{
+			Name: "float",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+				}
+
+				switch arg := args[0].(type) {
+				case *Integer:
+					return &Float{Value: float64(arg.Value)}
+				case *BigInt:
+					value, _ := new(big.Float).SetInt(arg.Value).Float64()
+					return &Float{Value: value}
+				case *Float:
+					return arg
+				case *String:
+					value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+					if err != nil {
+						return newError("could not convert %q to FLOAT", arg.Value)
+					}
+					return &Float{Value: value}
+				default:
+					return newError("`float` builtin function doesn't support argument of type %s", arg.Type())
+				}
+			},
+		},
+	},
+	{"round", &Builtin{Name: "round", Fn: roundingBuiltin("round", math.Round)}},
+	{"floor", &Builtin{Name: "floor", Fn: roundingBuiltin("floor", math.Floor)}},
+	{"ceil", &Builtin{Name: "ceil", Fn: roundingBuiltin("ceil", math.Ceil)}},
+	{
+		"bytes_len",
+		&Builtin{
+			Name: "bytes_len",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+				}
+
+				if args[0].Type() != STRING_OBJ {
+					return newError("argument to `bytes_len` must be STRING, got %s", args[0].Type())
+				}
+
+				return &Integer{Value: int64(len(args[0].(*String).Value))}
+			},
+		},
+	},
+	{
+		"slice",
+		&Builtin{
+			Name: "slice",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 2 && lenArgs != 3 {
+					return newError("wrong number of arguments. got=%d, want 2 or 3", lenArgs)
+				}
+
+				bounds := make([]int64, 0, 2)
+				for _, arg := range args[1:] {
+					integer, ok := arg.(*Integer)
+					if !ok {
+						return newError("slice bounds must be INTEGER, got %s", arg.Type())
+					}
+					bounds = append(bounds, integer.Value)
+				}
+
+				switch arg := args[0].(type) {
+				case *String:
+					runes := []rune(arg.Value)
+					start, end := sliceBounds(bounds, len(runes))
+					return &String{Value: string(runes[start:end])}
+				case *Array:
+					start, end := sliceBounds(bounds, len(arg.Elements))
+					elements := make([]Object, end-start)
+					copy(elements, arg.Elements[start:end])
+					return &Array{Elements: elements}
+				default:
+					return newError("argument to `slice` must be STRING or ARRAY, got %s", arg.Type())
+				}
+			},
+		},
+	},
+	{
+		"range",
+		&Builtin{
+			Name: "range",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs < 1 || lenArgs > 3 {
+					return newError("wrong number of arguments. got=%d, want 1 to 3", lenArgs)
+				}
+
+				values := make([]int64, len(args))
+				for i, arg := range args {
+					integer, ok := arg.(*Integer)
+					if !ok {
+						return newError("arguments to `range` must be INTEGER, got %s", arg.Type())
+					}
+					values[i] = integer.Value
+				}
+
+				r := &Range{Step: 1}
+				switch len(values) {
+				case 1:
+					r.End = values[0]
+				case 2:
+					r.Start, r.End = values[0], values[1]
+				case 3:
+					r.Start, r.End, r.Step = values[0], values[1], values[2]
+				}
+
+				if r.Step == 0 {
+					return newError("`range` step must not be zero")
+				}
+
+				return r
+			},
+		},
+	},
+	{"puts", &Builtin{Name: "puts", Fn: PutsBuiltin("puts", os.Stdout)}},
+	{"eputs", &Builtin{Name: "eputs", Fn: PutsBuiltin("eputs", os.Stderr)}},
+	{
+		"map",
+		&Builtin{
+			Name: "map",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", lenArgs)
+				}
+
+				arr, ok := args[0].(*Array)
+				if !ok {
+					return newError("first argument to `map` must be ARRAY, got %s", args[0].Type())
+				}
+
+				mapped := make([]Object, len(arr.Elements))
+				for i, element := range arr.Elements {
+					result := call(ctx, "map", args[1], element)
+					if isError(result) {
+						return result
+					}
+
+					mapped[i] = result
+				}
+
+				return &Array{Elements: mapped}
+			},
+		},
+	},
+	{
+		"sort",
+		&Builtin{
+			Name: "sort",
+			Fn: func(ctx CallContext, args ...Object) Object {
+				if lenArgs := len(args); lenArgs != 1 && lenArgs != 2 {
+					return newError("wrong number of arguments. got=%d, want 1 or 2", lenArgs)
+				}
+
+				arr, ok := args[0].(*Array)
+				if !ok {
+					return newError("first argument to `sort` must be ARRAY, got %s", args[0].Type())
+				}
+
+				sorted := make([]Object, len(arr.Elements))
+				copy(sorted, arr.Elements)
+
+				less := naturalLess
+				if len(args) == 2 {
+					less = func(a, b Object) (bool, Object) {
+						result := call(ctx, "sort", args[1], a, b)
+						if isError(result) {
+							return false, result
+						}
+
+						return isTruthy(result), nil
+					}
+				}
+
+				// The first error stops further comparisons and is returned
+				// once sort.SliceStable is done.
+				var failure Object
+				sort.SliceStable(sorted, func(i, j int) bool {
+					if failure != nil {
+						return false
+					}
+
+					result, err := less(sorted[i], sorted[j])
+					failure = err
+					return result
+				})
+
+				if failure != nil {
+					return failure
+				}
+
+				return &Array{Elements: sorted}
+			},
+		},
+	},
+}
+
+// ExitBuiltin returns the `exit` builtin, which passes its status code,
+// 0 by default, to exit and returns whatever that returns.
+func ExitBuiltin(exit func(code int) Object) BuiltinFunction {
+	return func(ctx CallContext, args ...Object) Object {
+		lenArgs := len(args)
+
+		if lenArgs == 0 {
+			return exit(0)
+		}
+
+		if lenArgs > 1 {
+			return newError("wrong number of arguments. got=%d, want 0 or 1", lenArgs)
+		}
+
+		switch arg := args[0].(type) {
+		case *Integer:
+			return exit(int(arg.Value))
+		default:
+			return newError("`exit` builtin function doesn't support argument of type %s", arg.Type())
+		}
+	}
+}
+
+// PutsBuiltin returns a builtin that writes each of its arguments to w on
+// a line of its own.
+func PutsBuiltin(name string, w io.Writer) BuiltinFunction {
+	return func(ctx CallContext, args ...Object) Object {
+		for _, arg := range args {
+			if _, err := fmt.Fprintln(w, arg.Inspect()); err != nil {
+				return newError("`%s` could not write: %s", name, err)
+			}
+		}
+
+		return nil
+	}
+}
+
+// sliceBounds resolves the start and optional end arguments of `slice`
+// against a sequence of length n. Negative bounds count from the end and
+// out-of-range bounds are clamped, so the result is always a valid range.
+func sliceBounds(bounds []int64, n int) (int, int) {
+	resolve := func(i int64) int {
+		if i < 0 {
+			i += int64(n)
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > int64(n) {
+			return n
+		}
+		return int(i)
+	}
+
+	start, end := resolve(bounds[0]), n
+	if len(bounds) > 1 {
+		end = resolve(bounds[1])
+	}
+	if start > end {
+		start = end
+	}
+
+	return start, end
+}
+
+// roundingBuiltin returns a builtin that rounds a FLOAT to an INTEGER with
+// fn. Integers are already whole and are returned unchanged.
+func roundingBuiltin(name string, fn func(float64) float64) BuiltinFunction {
+	return func(ctx CallContext, args ...Object) Object {
+		if lenArgs := len(args); lenArgs != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", lenArgs)
+		}
+
+		switch arg := args[0].(type) {
+		case *Integer, *BigInt:
+			return arg
+		case *Float:
+			value := fn(arg.Value)
+			if math.IsInf(value, 0) || math.IsNaN(value) {
+				return newError("could not convert %s to INTEGER", arg.Inspect())
+			}
+			rounded, _ := big.NewFloat(value).Int(nil)
+			return NewInteger(rounded)
+		default:
+			return newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+		}
+	}
+}
+
+// call calls fn on behalf of the builtin called name.
+func call(ctx CallContext, name string, fn Object, args ...Object) Object {
+	if ctx == nil {
+		return newError("`%s` cannot call functions outside an engine", name)
+	}
+
+	return ctx.Call(fn, args...)
+}
+
+// naturalLess orders numbers by value and strings lexically, which is how
+// `sort` orders elements without a comparison function.
+func naturalLess(a, b Object) (bool, Object) {
+	switch {
+	case a.Type() == FLOAT_OBJ || b.Type() == FLOAT_OBJ:
+		x, xok := floatValue(a)
+		y, yok := floatValue(b)
+		if !xok || !yok {
+			break
+		}
+
+		return x < y, nil
+	case a.Type() != STRING_OBJ && b.Type() != STRING_OBJ:
+		x, xok := BigValue(a)
+		y, yok := BigValue(b)
+		if !xok || !yok {
+			break
+		}
+
+		return x.Cmp(y) < 0, nil
+	case a.Type() == STRING_OBJ && b.Type() == STRING_OBJ:
+		return a.(*String).Value < b.(*String).Value, nil
+	}
+
+	return false, newError("`sort` cannot compare %s and %s", a.Type(), b.Type())
+}
+
+func floatValue(obj Object) (float64, bool) {
+	if f, ok := obj.(*Float); ok {
+		return f.Value, true
+	}
+
+	if i, ok := BigValue(obj); ok {
+		f, _ := new(big.Float).SetInt(i).Float64()
+		return f, true
+	}
+
+	return 0, false
+}
+
+func isError(obj Object) bool {
+	_, ok := obj.(*Error)
+	return ok
+}
+
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return false
+	default:
+		return true
+	}
+}
+
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+
+	return nil
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,576 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	ctxType    = reflect.TypeOf((*CallContext)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// ToObject converts a Go value to an Object. It handles booleans, numbers,
+// strings, *big.Int, slices, arrays, maps, structs and pointers to any of
+// them; nil becomes null and an Object is returned as it is. Struct fields
+// are named after the field unless a `monkey:"name"` tag says otherwise,
+// and `monkey:"-"` leaves a field out, as do unexported fields. A value
+// that contains itself through a pointer, slice or map is an error.
+func ToObject(v any) (Object, error) {
+	if v == nil {
+		return &Null{}, nil
+	}
+
+	return toObject(reflect.ValueOf(v), make(map[visit]bool))
+}
+
+// visit identifies a pointer, slice or map that is being converted. The
+// length tells apart slices that share their first element.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// enter records that v is being converted, and fails if it already is,
+// which means v contains itself. Values reached twice without a cycle are
+// converted twice, so leave must undo enter once v is done.
+func enter(v reflect.Value, seen map[visit]bool) (visit, error) {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+
+	if seen[key] {
+		return key, fmt.Errorf("cannot convert cyclic value of type %s", v.Type())
+	}
+
+	seen[key] = true
+
+	return key, nil
+}
+
+func toObject(v reflect.Value, seen map[visit]bool) (Object, error) {
+	if obj, ok := v.Interface().(Object); ok {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return &Null{}, nil
+		}
+
+		return obj, nil
+	}
+
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+
+		return NewInteger(new(big.Int).Set(v.Interface().(*big.Int))), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return NativeBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewInteger(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+
+		if v.Kind() == reflect.Pointer {
+			key, err := enter(v, seen)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(seen, key)
+		}
+
+		return toObject(v.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return &Null{}, nil
+			}
+
+			key, err := enter(v, seen)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(seen, key)
+		}
+
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			element, err := toObject(v.Index(i), seen)
+			if err != nil {
+				return nil, err
+			}
+
+			elements[i] = element
+		}
+
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return &Null{}, nil
+		}
+
+		key, err := enter(v, seen)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(seen, key)
+
+		return mapToHash(v, seen)
+	case reflect.Struct:
+		return structToHash(v, seen)
+	default:
+		return nil, fmt.Errorf("cannot convert Go value of type %s", v.Type())
+	}
+}
+
+// mapToHash converts a map, sorting its keys so the hash has the same
+// order every time. Numbers are ordered by value and strings
+// lexicographically; keys of different types are grouped by type.
+func mapToHash(v reflect.Value, seen map[visit]bool) (Object, error) {
+	type entry struct {
+		key   Hashable
+		value Object
+	}
+
+	entries := make([]entry, 0, v.Len())
+
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := toObject(iter.Key(), seen)
+		if err != nil {
+			return nil, err
+		}
+
+		hashable, ok := key.(Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		value, err := toObject(iter.Value(), seen)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry{hashable, value})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return keyLess(entries[i].key.(Object), entries[j].key.(Object))
+	})
+
+	hash := NewHash()
+	for _, e := range entries {
+		hash.Set(e.key.HashKey(), HashPair{Key: e.key.(Object), Value: e.value})
+	}
+
+	return hash, nil
+}
+
+// keyLess orders the keys of a converted map.
+func keyLess(a, b Object) bool {
+	if less, err := naturalLess(a, b); err == nil {
+		return less
+	}
+
+	if IsNumber(a) != IsNumber(b) {
+		return IsNumber(a)
+	}
+
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	return a.Inspect() < b.Inspect()
+}
+
+func structToHash(v reflect.Value, seen map[visit]bool) (Object, error) {
+	hash := NewHash()
+
+	for _, field := range reflect.VisibleFields(v.Type()) {
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+
+		value, err := toObject(v.FieldByIndex(field.Index), seen)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		key := &String{Value: name}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+// fieldName returns the hash key a struct field is stored under, and false
+// if the field is not converted at all.
+func fieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() || field.Anonymous {
+		return "", false
+	}
+
+	switch tag := field.Tag.Get("monkey"); tag {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return tag, true
+	}
+}
+
+// FromObject stores obj in the Go value out points to, converting it the
+// opposite way to ToObject. An integer converts to any Go number type it
+// fits in, and into an interface{} the natural Go type is used: int64,
+// float64, string, bool, nil, []any or map[any]any.
+func FromObject(obj Object, out any) error {
+	ptr := reflect.ValueOf(out)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return errors.New("FromObject needs a non-nil pointer")
+	}
+
+	v, err := fromObject(obj, ptr.Type().Elem(), make(map[Object]bool))
+	if err != nil {
+		return err
+	}
+
+	ptr.Elem().Set(v)
+
+	return nil
+}
+
+// fromObject converts obj for the Go type t. seen holds the arrays and
+// hashes being converted, so that one that contains itself is an error.
+func fromObject(obj Object, t reflect.Type, seen map[Object]bool) (reflect.Value, error) {
+	if obj == nil {
+		return reflect.Value{}, fmt.Errorf("cannot use nil Object as Go %s", t)
+	}
+
+	if t == objectType {
+		return reflect.ValueOf(&obj).Elem(), nil
+	}
+
+	native := t.Kind() == reflect.Interface && t.NumMethod() == 0
+	if !native && reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+
+	switch obj.(type) {
+	case *Array, *Hash:
+		if seen[obj] {
+			return reflect.Value{}, fmt.Errorf("cannot convert %s that contains itself", obj.Type())
+		}
+
+		seen[obj] = true
+		defer delete(seen, obj)
+	}
+
+	if native {
+		return nativeValue(obj, t, seen)
+	}
+
+	if _, ok := obj.(*Null); ok {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	if t == bigIntType {
+		if value, ok := BigValue(obj); ok {
+			return reflect.ValueOf(new(big.Int).Set(value)), nil
+		}
+
+		return reflect.Value{}, mismatch(obj, t)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*Integer); ok {
+			v := reflect.New(t).Elem()
+			if v.OverflowInt(i.Value) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+			}
+
+			v.SetInt(i.Value)
+			return v, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value, ok := BigValue(obj); ok {
+			v := reflect.New(t).Elem()
+			if value.Sign() < 0 || !value.IsUint64() || v.OverflowUint(value.Uint64()) {
+				return reflect.Value{}, fmt.Errorf("%s overflows %s", value, t)
+			}
+
+			v.SetUint(value.Uint64())
+			return v, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		v := reflect.New(t).Elem()
+
+		switch obj := obj.(type) {
+		case *Float:
+			v.SetFloat(obj.Value)
+			return v, nil
+		case *Integer:
+			v.SetFloat(float64(obj.Value))
+			return v, nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*String); ok {
+			return reflect.ValueOf(s.Value).Convert(t), nil
+		}
+	case reflect.Pointer:
+		elem, err := fromObject(obj, t.Elem(), seen)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case reflect.Slice:
+		if arr, ok := obj.(*Array); ok {
+			v := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+			return v, fillElements(v, arr.Elements, t.Elem(), seen)
+		}
+	case reflect.Array:
+		if arr, ok := obj.(*Array); ok {
+			if len(arr.Elements) != t.Len() {
+				return reflect.Value{}, fmt.Errorf("array of %d elements does not fit %s", len(arr.Elements), t)
+			}
+
+			v := reflect.New(t).Elem()
+			return v, fillElements(v, arr.Elements, t.Elem(), seen)
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*Hash); ok {
+			v := reflect.MakeMapWithSize(t, len(hash.Pairs))
+
+			for _, pair := range hash.Ordered() {
+				key, err := fromObject(pair.Key, t.Key(), seen)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				value, err := fromObject(pair.Value, t.Elem(), seen)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				v.SetMapIndex(key, value)
+			}
+
+			return v, nil
+		}
+	case reflect.Struct:
+		if hash, ok := obj.(*Hash); ok {
+			return hashToStruct(hash, t, seen)
+		}
+	}
+
+	return reflect.Value{}, mismatch(obj, t)
+}
+
+func fillElements(v reflect.Value, elements []Object, t reflect.Type, seen map[Object]bool) error {
+	for i, element := range elements {
+		value, err := fromObject(element, t, seen)
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+
+		v.Index(i).Set(value)
+	}
+
+	return nil
+}
+
+func hashToStruct(hash *Hash, t reflect.Type, seen map[Object]bool) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+
+	for _, field := range reflect.VisibleFields(t) {
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+
+		pair, ok := hash.Get((&String{Value: name}).HashKey())
+		if !ok {
+			continue
+		}
+
+		value, err := fromObject(pair.Value, field.Type, seen)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		v.FieldByIndex(field.Index).Set(value)
+	}
+
+	return v, nil
+}
+
+// nativeValue converts obj for an empty interface type t.
+func nativeValue(obj Object, t reflect.Type, seen map[Object]bool) (reflect.Value, error) {
+	var native any
+
+	switch obj := obj.(type) {
+	case *Null:
+		return reflect.Zero(t), nil
+	case *Boolean:
+		native = obj.Value
+	case *Integer:
+		native = obj.Value
+	case *BigInt:
+		native = new(big.Int).Set(obj.Value)
+	case *Float:
+		native = obj.Value
+	case *String:
+		native = obj.Value
+	case *Array:
+		elements := make([]any, len(obj.Elements))
+		if err := fillElements(reflect.ValueOf(elements), obj.Elements, t, seen); err != nil {
+			return reflect.Value{}, err
+		}
+
+		native = elements
+	case *Hash:
+		m := make(map[any]any, len(obj.Pairs))
+		for _, pair := range obj.Ordered() {
+			key, err := fromObject(pair.Key, t, seen)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			value, err := fromObject(pair.Value, t, seen)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+
+			m[key.Interface()] = value.Interface()
+		}
+
+		native = m
+	default:
+		native = obj
+	}
+
+	v := reflect.New(t).Elem()
+	v.Set(reflect.ValueOf(native))
+
+	return v, nil
+}
+
+func mismatch(obj Object, t reflect.Type) error {
+	return fmt.Errorf("cannot use %s as Go %s", obj.Type(), t)
+}
+
+// GoFunc wraps the Go function fn as a builtin called name. Arguments are
+// converted with FromObject to fn's parameter types, checking their count
+// against fn's signature, and fn may return nothing, a value, an error, or
+// a value and an error. A non-nil error becomes an error object and the
+// value is converted with ToObject. If fn's first parameter is a
+// CallContext, it gets the builtin's, which it can use to call functions
+// passed to it.
+func GoFunc(name string, fn any) (*Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("%s: %T is not a function", name, fn)
+	}
+
+	t := v.Type()
+
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	values := t.NumOut()
+	if returnsError {
+		values--
+	}
+
+	if values > 1 {
+		return nil, fmt.Errorf("%s: functions may return at most one value and an error, %s returns %d values", name, t, t.NumOut())
+	}
+
+	skip := 0
+	if t.NumIn() > 0 && t.In(0) == ctxType {
+		skip = 1
+	}
+
+	min, max := t.NumIn()-skip, t.NumIn()-skip
+	if t.IsVariadic() {
+		min, max = min-1, -1
+	}
+
+	builtin := func(ctx CallContext, args ...Object) Object {
+		if len(args) < min || (max >= 0 && len(args) > max) {
+			return ArityError(name, min, max, len(args))
+		}
+
+		in := make([]reflect.Value, skip, skip+len(args))
+		if skip > 0 {
+			in[0] = reflect.ValueOf(&ctx).Elem()
+		}
+
+		for i, arg := range args {
+			value, err := fromObject(arg, paramType(t, skip+i), make(map[Object]bool))
+			if err != nil {
+				return newError("argument %d to `%s`: %s", i+1, name, err)
+			}
+
+			in = append(in, value)
+		}
+
+		out := v.Call(in)
+
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return newError("%s", err)
+			}
+		}
+
+		if values == 0 {
+			return nil
+		}
+
+		result, err := toObject(out[0], make(map[visit]bool))
+		if err != nil {
+			return newError("result of `%s`: %s", name, err)
+		}
+
+		return result
+	}
+
+	return &Builtin{Name: name, Fn: builtin}, nil
+}
+
+// paramType returns the type of the i-th argument to a function of type t,
+// which is the element type of the variadic parameter past its start.
+func paramType(t reflect.Type, i int) reflect.Type {
+	if t.IsVariadic() && i >= t.NumIn()-1 {
+		return t.In(t.NumIn() - 1).Elem()
+	}
+
+	return t.In(i)
+}
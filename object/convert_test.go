@@ -0,0 +1,294 @@
+package object
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	X      int     `monkey:"x"`
+	Y      float64 `monkey:"y"`
+	Label  string
+	Hidden bool `monkey:"-"`
+	secret int
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{42, "42"},
+		{int8(-3), "-3"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{2.5, "2.5"},
+		{"hi", "hi"},
+		{big.NewInt(7), "7"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{[]int(nil), "null"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{map[int]string{10: "ten", 2: "two", -1: "minus one"}, "{-1: minus one, 2: two, 10: ten}"},
+		{map[any]int{"b": 1, 10: 2, uint64(math.MaxUint64): 3, true: 4}, "{10: 2, 18446744073709551615: 3, true: 4, b: 1}"},
+		{point{X: 1, Y: 2, Label: "p", Hidden: true, secret: 3}, "{x: 1, y: 2.0, Label: p}"},
+		{&point{X: 1}, "{x: 1, y: 0.0, Label: }"},
+		{(*point)(nil), "null"},
+		{[]any{1, "two", nil}, "[1, two, null]"},
+		{&Integer{Value: 5}, "5"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v) failed: %s", tt.input, err)
+			continue
+		}
+
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	if _, err := ToObject(make(chan int)); err == nil {
+		t.Errorf("expected an error converting a channel")
+	}
+
+	if obj, _ := ToObject(true); obj != True {
+		t.Errorf("ToObject(true) is not the shared True")
+	}
+}
+
+type node struct {
+	Value int
+	Next  *node
+}
+
+func TestToObjectCycles(t *testing.T) {
+	list := &node{Value: 1}
+	list.Next = &node{Value: 2, Next: list}
+
+	m := map[string]any{}
+	m["self"] = m
+
+	s := []any{nil}
+	s[0] = s
+
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{list, "cannot convert cyclic value of type *object.node"},
+		{m, "cannot convert cyclic value of type map[string]interface {}"},
+		{s, "cannot convert cyclic value of type []interface {}"},
+	}
+
+	for _, tt := range tests {
+		_, err := ToObject(tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+
+	shared := &node{Value: 3}
+	obj, err := ToObject([]*node{shared, shared})
+	if err != nil {
+		t.Fatalf("ToObject of a shared pointer failed: %s", err)
+	}
+
+	if want := "[{Value: 3, Next: null}, {Value: 3, Next: null}]"; obj.Inspect() != want {
+		t.Errorf("shared pointer wrong. want=%q, got=%q", want, obj.Inspect())
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	obj, err := ToObject(map[string]any{
+		"x":     3,
+		"y":     1.5,
+		"Label": "here",
+		"tags":  []string{"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("ToObject failed: %s", err)
+	}
+
+	var p point
+	if err := FromObject(obj, &p); err != nil {
+		t.Fatalf("FromObject failed: %s", err)
+	}
+
+	if want := (point{X: 3, Y: 1.5, Label: "here"}); p != want {
+		t.Errorf("struct wrong. want=%+v, got=%+v", want, p)
+	}
+
+	var native any
+	if err := FromObject(obj, &native); err != nil {
+		t.Fatalf("FromObject failed: %s", err)
+	}
+
+	want := map[any]any{"Label": "here", "tags": []any{"a", "b"}, "x": int64(3), "y": 1.5}
+	if !reflect.DeepEqual(native, want) {
+		t.Errorf("native value wrong. want=%#v, got=%#v", want, native)
+	}
+
+	var f float64
+	if err := FromObject(&Integer{Value: 2}, &f); err != nil || f != 2 {
+		t.Errorf("integer to float64 wrong. got=%v, err=%v", f, err)
+	}
+
+	var ptr *int
+	if err := FromObject(&Null{}, &ptr); err != nil || ptr != nil {
+		t.Errorf("null to pointer wrong. got=%v, err=%v", ptr, err)
+	}
+}
+
+func TestFromObjectErrors(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+
+	nested := &Array{}
+	nested.Elements = []Object{nested}
+
+	hash := NewHash()
+	key := &String{Value: "self"}
+	hash.Set(key.HashKey(), HashPair{Key: key, Value: hash})
+
+	tests := []struct {
+		obj      Object
+		out      any
+		expected string
+	}{
+		{&String{Value: "x"}, new(int), "cannot use STRING as Go int"},
+		{&Integer{Value: 300}, new(int8), "300 overflows int8"},
+		{&Integer{Value: -1}, new(uint), "-1 overflows uint"},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, new([2]int), "array of 1 elements does not fit [2]int"},
+		{&Array{Elements: []Object{&Boolean{Value: true}}}, new([]string), "element 0: cannot use BOOLEAN as Go string"},
+		{nil, new(int), "cannot use nil Object as Go int"},
+		{nil, new(any), "cannot use nil Object as Go interface {}"},
+		{&Array{Elements: []Object{nil}}, new([]int), "element 0: cannot use nil Object as Go int"},
+		{array, new(any), "element 1: cannot convert ARRAY that contains itself"},
+		{nested, new([][]any), "element 0: cannot convert ARRAY that contains itself"},
+		{hash, new(any), "cannot convert HASH that contains itself"},
+		{hash, new(map[string]map[string]any), "cannot convert HASH that contains itself"},
+	}
+
+	for _, tt := range tests {
+		err := FromObject(tt.obj, tt.out)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+
+	if err := FromObject(&Integer{Value: 1}, 0); err == nil {
+		t.Errorf("expected an error for a non-pointer")
+	}
+
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+	var native any
+	if err := FromObject(&Array{Elements: []Object{shared, shared}}, &native); err != nil {
+		t.Errorf("FromObject of a shared array failed: %s", err)
+	}
+}
+
+func TestGoFunc(t *testing.T) {
+	add, err := GoFunc("add", func(a, b int) int { return a + b })
+	if err != nil {
+		t.Fatalf("GoFunc failed: %s", err)
+	}
+
+	join, _ := GoFunc("join", func(sep string, parts ...string) string { return strings.Join(parts, sep) })
+	fail, _ := GoFunc("fail", func(ok bool) (string, error) {
+		if !ok {
+			return "", errors.New("it failed")
+		}
+		return "fine", nil
+	})
+	nothing, _ := GoFunc("nothing", func() {})
+	ignore, _ := GoFunc("ignore", func(x any) {})
+
+	cyclic := &Array{}
+	cyclic.Elements = []Object{cyclic}
+
+	tests := []struct {
+		fn       *Builtin
+		args     []Object
+		expected string
+	}{
+		{add, []Object{&Integer{Value: 1}, &Integer{Value: 2}}, "3"},
+		{add, []Object{&Integer{Value: 1}}, "ERROR: wrong number of arguments to add: want=2, got=1"},
+		{add, []Object{&Integer{Value: 1}, &String{Value: "2"}}, "ERROR: argument 2 to `add`: cannot use STRING as Go int"},
+		{join, []Object{&String{Value: "-"}, &String{Value: "a"}, &String{Value: "b"}}, "a-b"},
+		{join, []Object{&String{Value: "-"}}, ""},
+		{join, []Object{}, "ERROR: wrong number of arguments to join: want=at least 1, got=0"},
+		{fail, []Object{&Boolean{Value: true}}, "fine"},
+		{fail, []Object{&Boolean{Value: false}}, "ERROR: it failed"},
+		{ignore, []Object{cyclic}, "ERROR: argument 1 to `ignore`: element 0: cannot convert ARRAY that contains itself"},
+	}
+
+	for _, tt := range tests {
+		result := tt.fn.Fn(nil, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s wrong. want=%q, got=%q", tt.fn.Name, tt.expected, result.Inspect())
+		}
+	}
+
+	if result := nothing.Fn(nil); result != nil {
+		t.Errorf("expected nil from a function without results. got=%v", result)
+	}
+
+	if _, err := GoFunc("bad", 42); err == nil {
+		t.Errorf("expected an error for a non-function")
+	}
+
+	if _, err := GoFunc("bad", func() (int, int) { return 1, 2 }); err == nil {
+		t.Errorf("expected an error for two results")
+	}
+}
+
+func TestGoFuncCallContext(t *testing.T) {
+	apply, _ := GoFunc("apply", func(ctx CallContext, fn Object, arg int) Object {
+		return ctx.Call(fn, &Integer{Value: int64(arg)})
+	})
+
+	neg := &Builtin{Name: "neg", Fn: func(ctx CallContext, args ...Object) Object {
+		return &Integer{Value: -args[0].(*Integer).Value}
+	}}
+
+	result := apply.Fn(callContext{}, neg, &Integer{Value: 4})
+	if result.Inspect() != "-4" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	if result := apply.Fn(callContext{}, neg); result.Inspect() != "ERROR: wrong number of arguments to apply: want=2, got=1" {
+		t.Errorf("wrong arity error. got=%q", result.Inspect())
+	}
+}
+
+func TestHigherOrderBuiltinsNeedACallContext(t *testing.T) {
+	double := &Builtin{Name: "double", Fn: func(ctx CallContext, args ...Object) Object {
+		return &Integer{Value: 2 * args[0].(*Integer).Value}
+	}}
+	arr := &Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}
+
+	mapBuiltin := GetBuiltinByName("map")
+
+	if result := mapBuiltin.Fn(callContext{}, arr, double); result.Inspect() != "[2, 4]" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+
+	if result := mapBuiltin.Fn(nil, arr, double); result.Inspect() != "ERROR: `map` cannot call functions outside an engine" {
+		t.Errorf("wrong error. got=%q", result.Inspect())
+	}
+}
+
+// callContext calls builtins directly, which is all these tests need.
+type callContext struct{}
+
+func (callContext) Call(fn Object, args ...Object) Object {
+	return fn.(*Builtin).Fn(callContext{}, args...)
+}
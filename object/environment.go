@@ -1,8 +1,10 @@
 package object
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	outer   *Environment
+	budget  *Budget
+	modules *Modules
 }
 
 func NewEnvironment() *Environment {
@@ -13,10 +15,48 @@ func NewEnvironment() *Environment {
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.budget = outer.Budget()
+	env.modules = outer.Modules()
 
 	return env
 }
 
+// Budget returns the budget of the evaluation running in e, or nil if it
+// is unlimited. Enclosed environments start out with their outer one's.
+func (e *Environment) Budget() *Budget {
+	if e == nil {
+		return nil
+	}
+
+	return e.budget
+}
+
+func (e *Environment) SetBudget(b *Budget) {
+	e.budget = b
+}
+
+// Modules returns the modules import expressions evaluated in e load, or
+// nil if importing is not possible. Enclosed environments start out with
+// their outer one's.
+func (e *Environment) Modules() *Modules {
+	if e == nil {
+		return nil
+	}
+
+	return e.modules
+}
+
+func (e *Environment) SetModules(m *Modules) {
+	e.modules = m
+}
+
+// Defines reports whether name is bound in e itself, not in its outer
+// environments.
+func (e *Environment) Defines(name string) bool {
+	_, ok := e.store[name]
+	return ok
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 
@@ -31,3 +71,16 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Assign rebinds name in the innermost environment that defines it. It
+// reports false, and changes nothing, when name is not defined anywhere.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return val, true
+		}
+	}
+
+	return nil, false
+}
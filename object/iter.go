@@ -0,0 +1,136 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// Iterable is implemented by the objects a for-in loop can walk.
+type Iterable interface {
+	Object
+	Iter() *Iterator
+}
+
+// Iterator yields the key/value pairs of an Iterable one at a time. It is
+// an Object itself so the VM can keep it on the stack while a loop runs.
+type Iterator struct {
+	next func() (key, value Object, ok bool)
+}
+
+func NewIterator(next func() (key, value Object, ok bool)) *Iterator {
+	return &Iterator{next: next}
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
+
+// Next returns the next pair, or ok=false once the iterator is exhausted.
+func (it *Iterator) Next() (key, value Object, ok bool) {
+	return it.next()
+}
+
+// Iter yields each index and element.
+func (a *Array) Iter() *Iterator {
+	i := 0
+
+	return NewIterator(func() (Object, Object, bool) {
+		if i >= len(a.Elements) {
+			return nil, nil, false
+		}
+
+		key, value := &Integer{Value: int64(i)}, a.Elements[i]
+		i++
+
+		return key, value, true
+	})
+}
+
+// Iter yields each rune index and the rune as a one-character string.
+func (s *String) Iter() *Iterator {
+	runes := []rune(s.Value)
+	i := 0
+
+	return NewIterator(func() (Object, Object, bool) {
+		if i >= len(runes) {
+			return nil, nil, false
+		}
+
+		key, value := &Integer{Value: int64(i)}, &String{Value: string(runes[i])}
+		i++
+
+		return key, value, true
+	})
+}
+
+// Iter yields each key and value in insertion order. Changes made to the
+// hash while iterating are not seen. Like every iterable, a for-in loop with
+// a single variable binds the values; the keys need `for (k, v in hash)`.
+func (h *Hash) Iter() *Iterator {
+	pairs := h.Ordered()
+	i := 0
+
+	return NewIterator(func() (Object, Object, bool) {
+		if i >= len(pairs) {
+			return nil, nil, false
+		}
+
+		pair := pairs[i]
+		i++
+
+		return pair.Key, pair.Value, true
+	})
+}
+
+// Range is the lazy sequence start, start+step, ... up to but excluding
+// end, as produced by the `range` builtin.
+type Range struct {
+	Start, End, Step int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// Len returns the number of values in the range, saturating at the
+// largest int64.
+func (r *Range) Len() int64 {
+	var span, step uint64
+
+	switch {
+	case r.Step > 0 && r.Start < r.End:
+		span, step = uint64(r.End)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && r.Start > r.End:
+		span, step = uint64(r.Start)-uint64(r.End), -uint64(r.Step)
+	default:
+		return 0
+	}
+
+	n := span / step
+	if span%step != 0 {
+		n++
+	}
+
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+
+	return int64(n)
+}
+
+// Iter yields each position and value of the range.
+func (r *Range) Iter() *Iterator {
+	var i int64
+	n := r.Len()
+
+	return NewIterator(func() (Object, Object, bool) {
+		if i >= n {
+			return nil, nil, false
+		}
+
+		key, value := &Integer{Value: i}, &Integer{Value: r.Start + i*r.Step}
+		i++
+
+		return key, value, true
+	})
+}
@@ -0,0 +1,162 @@
+package object
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// ModuleExt is added to import paths that do not name a file extension.
+const ModuleExt = ".mk"
+
+// Module is the value of an import expression. Its exports are the
+// top-level bindings of the module whose names do not start with an
+// underscore. They are live: once a function of the module assigns to one,
+// importers see the new value.
+type Module struct {
+	Name string
+
+	lookup func(name string) (Object, bool)
+}
+
+// NewModule returns the module called name, whose top-level bindings
+// lookup returns.
+func NewModule(name string, lookup func(name string) (Object, bool)) *Module {
+	return &Module{Name: name, lookup: lookup}
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "<module " + m.Name + ">" }
+
+// Export returns the current value of the binding the module exports as
+// name.
+func (m *Module) Export(name string) Object {
+	if Exported(name) && m.lookup != nil {
+		if value, ok := m.lookup(name); ok {
+			return value
+		}
+	}
+
+	return newError("module %s has no export %s", m.Name, name)
+}
+
+// Exported reports whether a top-level binding called name is exported.
+func Exported(name string) bool {
+	return !strings.HasPrefix(name, "_")
+}
+
+// Resolver finds the source of imported modules.
+type Resolver interface {
+	// Resolve returns the name of the module imported as importPath, which
+	// is the same however it is imported, without reading it.
+	Resolve(importPath string) (name string, err error)
+	// Source returns the source of the module Resolve named name.
+	Source(name string) (string, error)
+}
+
+// FSResolver resolves import paths to files in a file system. Paths are
+// slash-separated and relative to its root, and get ModuleExt when they
+// have no extension, so `import "lib/math"` reads lib/math.mk.
+type FSResolver struct {
+	fsys fs.FS
+}
+
+func NewFSResolver(fsys fs.FS) *FSResolver {
+	return &FSResolver{fsys: fsys}
+}
+
+// NewDirResolver returns a resolver for the modules under the directory
+// root.
+func NewDirResolver(root string) *FSResolver {
+	return NewFSResolver(os.DirFS(root))
+}
+
+func (r *FSResolver) Resolve(importPath string) (string, error) {
+	name := path.Clean(importPath)
+	if !fs.ValidPath(name) || name == "." {
+		return "", fmt.Errorf("invalid module path %q", importPath)
+	}
+
+	if path.Ext(name) == "" {
+		name += ModuleExt
+	}
+
+	if info, err := fs.Stat(r.fsys, name); err != nil || info.IsDir() {
+		return "", fmt.Errorf("cannot find module %q", importPath)
+	}
+
+	return name, nil
+}
+
+func (r *FSResolver) Source(name string) (string, error) {
+	src, err := fs.ReadFile(r.fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("cannot read module %s: %w", name, err)
+	}
+
+	return string(src), nil
+}
+
+// Modules loads modules for one program run. Each module is evaluated
+// once, the first time it is imported, and later imports share it.
+// Modules is not safe for concurrent use, and the modules of the evaluator
+// and the VM must not be mixed.
+type Modules struct {
+	resolver Resolver
+	scope    *Environment
+	cache    map[string]*Module
+	loading  []string
+}
+
+// NewModules returns modules found by resolver. The evaluator evaluates
+// each module in an environment enclosed by scope, or in a fresh one if
+// scope is nil; an embedder passes the environment holding its builtins.
+func NewModules(resolver Resolver, scope *Environment) *Modules {
+	return &Modules{resolver: resolver, scope: scope, cache: make(map[string]*Module)}
+}
+
+// Scope returns the environment module environments are enclosed by.
+func (m *Modules) Scope() *Environment {
+	return m.scope
+}
+
+// Load returns the module imported as importPath. If it has not been
+// loaded yet, its source is read and load is called with its name and
+// source to evaluate it. Importing a module that is still being loaded is
+// an import cycle.
+func (m *Modules) Load(importPath string, load func(name, src string) (*Module, error)) (*Module, error) {
+	name, err := m.resolver.Resolve(importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if module, ok := m.cache[name]; ok {
+		return module, nil
+	}
+
+	for i, loading := range m.loading {
+		if loading == name {
+			cycle := append(m.loading[i:len(m.loading):len(m.loading)], name)
+			return nil, fmt.Errorf("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	src, err := m.resolver.Source(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.loading = append(m.loading, name)
+	defer func() { m.loading = m.loading[:len(m.loading)-1] }()
+
+	module, err := load(name, src)
+	if err != nil {
+		return nil, err
+	}
+
+	m.cache[name] = module
+
+	return module, nil
+}
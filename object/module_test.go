@@ -0,0 +1,150 @@
+package object
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSResolver(t *testing.T) {
+	resolver := NewFSResolver(fstest.MapFS{
+		"lib/math.mk":   {Data: []byte("let pi = 3;")},
+		"data/conf.txt": {Data: []byte("let x = 1;")},
+	})
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"lib/math", "lib/math.mk"},
+		{"./lib/math", "lib/math.mk"},
+		{"lib/../lib/math.mk", "lib/math.mk"},
+		{"data/conf.txt", "data/conf.txt"},
+	}
+
+	for _, tt := range tests {
+		name, err := resolver.Resolve(tt.path)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %s", tt.path, err)
+			continue
+		}
+
+		if name != tt.expected {
+			t.Errorf("Resolve(%q) wrong. want=%q, got=%q", tt.path, tt.expected, name)
+		}
+
+		if src, err := resolver.Source(name); err != nil || src == "" {
+			t.Errorf("Source(%q) wrong. got=%q (%v)", name, src, err)
+		}
+	}
+
+	errors := []struct {
+		path     string
+		expected string
+	}{
+		{"lib/missing", `cannot find module "lib/missing"`},
+		{"lib", `cannot find module "lib"`},
+		{"../outside", `invalid module path "../outside"`},
+		{"/abs/path", `invalid module path "/abs/path"`},
+		{"", `invalid module path ""`},
+	}
+
+	for _, tt := range errors {
+		_, err := resolver.Resolve(tt.path)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("Resolve(%q) wrong error. want=%q, got=%v", tt.path, tt.expected, err)
+		}
+	}
+}
+
+// countingResolver counts the module sources it reads.
+type countingResolver struct {
+	*FSResolver
+	reads int
+}
+
+func (r *countingResolver) Source(name string) (string, error) {
+	r.reads++
+	return r.FSResolver.Source(name)
+}
+
+func TestModulesLoadEachModuleOnce(t *testing.T) {
+	resolver := &countingResolver{FSResolver: NewFSResolver(fstest.MapFS{
+		"a.mk": {Data: []byte("")},
+	})}
+	modules := NewModules(resolver, nil)
+
+	loads := 0
+	load := func(name, src string) (*Module, error) {
+		loads++
+		return &Module{Name: name}, nil
+	}
+
+	first, err := modules.Load("a", load)
+	if err != nil {
+		t.Fatalf("Load failed: %s", err)
+	}
+
+	second, err := modules.Load("./a.mk", load)
+	if err != nil {
+		t.Fatalf("Load failed: %s", err)
+	}
+
+	if first != second || loads != 1 {
+		t.Errorf("module loaded %d times", loads)
+	}
+
+	if resolver.reads != 1 {
+		t.Errorf("module source read %d times", resolver.reads)
+	}
+}
+
+func TestModulesDetectCycles(t *testing.T) {
+	modules := NewModules(NewFSResolver(fstest.MapFS{
+		"a.mk": {Data: []byte("b")},
+		"b.mk": {Data: []byte("c")},
+		"c.mk": {Data: []byte("b")},
+	}), nil)
+
+	var load func(name, src string) (*Module, error)
+	load = func(name, src string) (*Module, error) {
+		if _, err := modules.Load(src, load); err != nil {
+			return nil, err
+		}
+		return &Module{Name: name}, nil
+	}
+
+	_, err := modules.Load("a", load)
+
+	expected := "import cycle: b.mk -> c.mk -> b.mk"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("wrong error. want=%q, got=%v", expected, err)
+	}
+
+	if len(modules.loading) != 0 {
+		t.Errorf("modules still loading after the error: %v", modules.loading)
+	}
+}
+
+func TestModuleExport(t *testing.T) {
+	bindings := map[string]Object{"x": &Integer{Value: 1}, "_y": &Integer{Value: 2}}
+	module := NewModule("m.mk", func(name string) (Object, bool) {
+		value, ok := bindings[name]
+		return value, ok
+	})
+
+	if result := module.Export("x"); result.Inspect() != "1" {
+		t.Errorf("wrong export. got=%s", result.Inspect())
+	}
+
+	bindings["x"] = &Integer{Value: 3}
+	if result := module.Export("x"); result.Inspect() != "3" {
+		t.Errorf("export is not live. got=%s", result.Inspect())
+	}
+
+	for _, name := range []string{"y", "_y"} {
+		expected := "ERROR: module m.mk has no export " + name
+		if result := module.Export(name); result.Inspect() != expected {
+			t.Errorf("wrong error. want=%q, got=%q", expected, result.Inspect())
+		}
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"gomonkey/ast"
+	"gomonkey/code"
+	"gomonkey/token"
+	"hash/fnv"
+	"strconv"
 	"strings"
 )
 
@@ -15,10 +19,23 @@ const (
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	BIG_INTEGER_OBJ  = "BIG_INTEGER"
+	RANGE_OBJ        = "RANGE"
+	ITERATOR_OBJ     = "ITERATOR"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	STRING_OBJ       = "STRING"
 	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+	CELL_OBJ         = "CELL"
+	MODULE_OBJ       = "MODULE"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 )
 
 type Object interface {
@@ -33,12 +50,56 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect always shows a fractional part or exponent, so 2.0 is not
+// mistaken for the integer 2.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
 
 func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+
+	if b.Value {
+		value = 1
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+// True and False are the booleans both engines produce.
+var (
+	True  = &Boolean{Value: true}
+	False = &Boolean{Value: false}
+)
+
+// NativeBool returns True or False.
+func NativeBool(b bool) *Boolean {
+	if b {
+		return True
+	}
+
+	return False
+}
 
 type Null struct{}
 
@@ -54,32 +115,125 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Break and Continue unwind the statements of a loop body, the same way a
+// ReturnValue unwinds a function body.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// ErrorKind tells a host why evaluation failed.
+type ErrorKind int
+
+const (
+	// RuntimeError is a mistake in the program, such as a type mismatch.
+	RuntimeError ErrorKind = iota
+	// LimitError means the program ran out of its Budget or was cancelled.
+	LimitError
+	// ExitError means the program called `exit` in an interpreter that
+	// does not end the process.
+	ExitError
+)
+
+// Error is a runtime error. Pos, when known, is where in the source the
+// error was raised and is rendered in front of Message.
 type Error struct {
 	Message string
+	Pos     token.Position
+	Kind    ErrorKind
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Error() }
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Message
+	}
+
+	return e.Message
+}
 
 type Function struct {
 	Body       *ast.BlockStatement
 	Env        *Environment
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression
+	Rest       *ast.Identifier
+	Name       string
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+
+// Arity returns the least and the most arguments f accepts. max is -1 when
+// f has a rest parameter.
+func (f *Function) Arity() (min, max int) {
+	min = len(f.Parameters)
+	for min > 0 && min <= len(f.Defaults) && f.Defaults[min-1] != nil {
+		min--
+	}
+
+	if f.Rest != nil {
+		return min, -1
+	}
+
+	return min, len(f.Parameters)
+}
+
+// ArityError reports a call to a function named name, which may be empty,
+// with got arguments when it accepts between min and max of them. A max of
+// -1 means there is no upper limit.
+func ArityError(name string, min, max, got int) *Error {
+	var want string
+	switch {
+	case max < 0:
+		want = fmt.Sprintf("at least %d", min)
+	case min != max:
+		want = fmt.Sprintf("%d to %d", min, max)
+	default:
+		want = strconv.Itoa(min)
+	}
+
+	if name == "" {
+		return &Error{Message: fmt.Sprintf("wrong number of arguments: want=%s, got=%d", want, got)}
+	}
+
+	return &Error{Message: fmt.Sprintf("wrong number of arguments to %s: want=%s, got=%d", name, want, got)}
+}
+
 func (f *Function) Inspect() string {
+	return inspectFunction(f.Name, f.Parameters, f.Defaults, f.Rest, f.Body)
+}
+
+// inspectFunction prints a function the same way for both engines.
+func inspectFunction(name string, parameters []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range parameters {
+		if i < len(defaults) && defaults[i] != nil {
+			params = append(params, p.String()+" = "+defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+
+	if rest != nil {
+		params = append(params, "..."+rest.String())
 	}
 
-	out.WriteString("fn(")
+	out.WriteString("fn")
+	if name != "" {
+		out.WriteString(" " + name)
+	}
+	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n}")
 
 	return out.String()
@@ -91,8 +245,24 @@ type String struct {
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
 
-type BuiltinFunction func(args ...Object) Object
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// CallContext lets a builtin call back into the engine that is running
+// it, for example to apply a function it was passed as an argument.
+type CallContext interface {
+	// Call calls fn, which is a function or a builtin, with args. A call
+	// that fails returns an *Error, which the builtin should return too.
+	Call(fn Object, args ...Object) Object
+}
+
+// BuiltinFunction implements a builtin. ctx is nil when the builtin is
+// called from outside an engine.
+type BuiltinFunction func(ctx CallContext, args ...Object) Object
 
 type Builtin struct {
 	Fn   BuiltinFunction
@@ -107,17 +277,225 @@ type Array struct {
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
-func (a *Array) Inspect() string {
+func (a *Array) Inspect() string  { return inspect(a, make(map[Object]bool)) }
+
+// inspect returns obj.Inspect() for an array or hash that may contain
+// itself. seen holds the containers being printed, and one that is reached
+// again inside itself prints as [...] or {...}.
+func inspect(obj Object, seen map[Object]bool) string {
 	var out bytes.Buffer
 
-	elements := []string{}
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] {
+			return "[...]"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, seen))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+	case *Hash:
+		if seen[obj] {
+			return "{...}"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.Ordered() {
+			pairs = append(pairs, inspect(pair.Key, seen)+": "+inspect(pair.Value, seen))
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+	default:
+		return obj.Inspect()
 	}
 
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
+	return out.String()
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+type Hashable interface {
+	HashKey() HashKey
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash keeps its pairs in insertion order so that Inspect, `keys` and
+// `values` are deterministic.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Order []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string  { return inspect(h, make(map[Object]bool)) }
+
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	pair, ok := h.Pairs[key]
+	return pair, ok
+}
+
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.Order = append(h.Order, key)
+	}
+
+	h.Pairs[key] = pair
+}
+
+func (h *Hash) Delete(key HashKey) {
+	if _, ok := h.Pairs[key]; !ok {
+		return
+	}
+
+	delete(h.Pairs, key)
+
+	for i, k := range h.Order {
+		if k == key {
+			h.Order = append(h.Order[:i], h.Order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (h *Hash) Copy() *Hash {
+	hash := NewHash()
+
+	for _, key := range h.Order {
+		hash.Set(key, h.Pairs[key])
+	}
+
+	return hash
+}
+
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Order))
+
+	for _, key := range h.Order {
+		pairs = append(pairs, h.Pairs[key])
+	}
+
+	return pairs
+}
+
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+type Macro struct {
+	Body       *ast.BlockStatement
+	Env        *Environment
+	Parameters []*ast.Identifier
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
 
 	return out.String()
 }
+
+// CompiledFunction is a function compiled for the VM. NumParameters does
+// not count the rest parameter; the last NumDefaults parameters have
+// default values.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	SourceMap     *code.SourceMap
+	NumLocals     int
+	NumParameters int
+	NumDefaults   int
+	Variadic      bool
+	Name          string
+	// Literal is the function literal the function was compiled from, which
+	// Inspect prints. It is nil for the code of a program or module.
+	Literal *ast.FunctionLiteral
+}
+
+// Arity returns the least and the most arguments cf accepts. max is -1 when
+// cf has a rest parameter.
+func (cf *CompiledFunction) Arity() (min, max int) {
+	if cf.Variadic {
+		return cf.NumParameters - cf.NumDefaults, -1
+	}
+
+	return cf.NumParameters - cf.NumDefaults, cf.NumParameters
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Cell holds a local variable once a closure has captured it. The enclosing
+// frame and every closure share the cell, so an assignment made by any of
+// them is seen by all. Cells never escape the VM's own bookkeeping.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string  { return c.Value.Inspect() }
+
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+	// Unit is the unit of the code that created the closure, whose
+	// constants and globals its instructions refer to. A nil Unit runs in
+	// the caller's.
+	Unit *Unit
+}
+
+// Unit holds the constants and globals shared by code compiled together:
+// a program, or a module it imports.
+type Unit struct {
+	Constants []Object
+	Globals   []Object
+}
+
+// Closure reports itself as a FUNCTION so type names in error messages match
+// those produced by the evaluator.
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	if lit := c.Fn.Literal; lit != nil {
+		return inspectFunction(c.Fn.Name, lit.Parameters, lit.Defaults, lit.Rest, lit.Body)
+	}
+
+	return fmt.Sprintf("Closure[%p]", c)
+}
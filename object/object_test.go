@@ -0,0 +1,146 @@
+package object
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeysDoNotCollideAcrossTypes(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+
+	if one.HashKey() == yes.HashKey() {
+		t.Errorf("integer and boolean share a hash key")
+	}
+}
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	hash := NewHash()
+
+	for _, key := range []Object{&String{Value: "b"}, &Integer{Value: 1}, &String{Value: "a"}} {
+		hash.Set(key.(Hashable).HashKey(), HashPair{Key: key, Value: key})
+	}
+
+	hash.Delete((&Integer{Value: 1}).HashKey())
+	hash.Set((&String{Value: "b"}).HashKey(), HashPair{Key: &String{Value: "b"}, Value: &Integer{Value: 2}})
+
+	if inspect := hash.Inspect(); inspect != "{b: 2, a: a}" {
+		t.Errorf("hash.Inspect() wrong. got=%q", inspect)
+	}
+}
+
+func TestInspectCyclicValues(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+
+	hash := NewHash()
+	key := &String{Value: "self"}
+	hash.Set(key.HashKey(), HashPair{Key: key, Value: hash})
+
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+	twice := &Array{Elements: []Object{shared, shared}}
+
+	nested := NewHash()
+	nested.Set(key.HashKey(), HashPair{Key: key, Value: &Array{Elements: []Object{nested}}})
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{array, "[1, [...]]"},
+		{hash, "{self: {...}}"},
+		{twice, "[[2], [2]]"},
+		{nested, "{self: [{...}]}"},
+	}
+
+	for _, tt := range tests {
+		if inspect := tt.obj.Inspect(); inspect != tt.expected {
+			t.Errorf("Inspect() wrong. want=%q, got=%q", tt.expected, inspect)
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{0.5, "0.5"},
+		{2, "2.0"},
+		{-3, "-3.0"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %v. want=%q, got=%q", tt.value, tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestNewIntegerNormalizes(t *testing.T) {
+	small := NewInteger(big.NewInt(42))
+	if integer, ok := small.(*Integer); !ok || integer.Value != 42 {
+		t.Errorf("small value not demoted to Integer. got=%T (%+v)", small, small)
+	}
+
+	value, _ := new(big.Int).SetString("9223372036854775808", 10)
+	large := NewInteger(value)
+	if _, ok := large.(*BigInt); !ok {
+		t.Errorf("large value not kept as BigInt. got=%T (%+v)", large, large)
+	}
+}
+
+func TestBudgetCallDepth(t *testing.T) {
+	b := NewBudget(context.Background(), 0, 2)
+
+	for i := 0; i < 2; i++ {
+		if err := b.Enter(); err != nil {
+			t.Fatalf("Enter %d failed: %s", i, err)
+		}
+	}
+
+	if err := b.Enter(); err == nil || err.Kind != LimitError {
+		t.Fatalf("third Enter not rejected with a LimitError. got=%+v", err)
+	}
+
+	b.Leave()
+	if err := b.Enter(); err != nil {
+		t.Fatalf("Enter after Leave failed: %s", err)
+	}
+}
+
+func TestNilBudgetAllowsEverything(t *testing.T) {
+	var b *Budget
+
+	if err := b.Step(); err != nil {
+		t.Errorf("Step failed: %s", err)
+	}
+	if err := b.Enter(); err != nil {
+		t.Errorf("Enter failed: %s", err)
+	}
+	b.Leave()
+}
@@ -0,0 +1,20 @@
+package parser
+
+import (
+	"fmt"
+	"gomonkey/token"
+)
+
+// ParseError describes a single syntax error. Expected lists the token types
+// that would have been accepted at Pos; it is empty when the parser was
+// looking for an expression rather than a particular token.
+type ParseError struct {
+	Pos      token.Position
+	Expected []token.TokenType
+	Found    token.Token
+	Msg      string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
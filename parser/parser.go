@@ -1,36 +1,64 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"gomonkey/ast"
 	"gomonkey/lexer"
 	"gomonkey/token"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	LOGICAL_OR
+	LOGICAL_AND
+	BIT_OR
+	BIT_XOR
+	BIT_AND
 	EQUALS
 	LESSGREATER
+	SHIFT
 	SUM
 	PRODUCT
 	PREFIX
+	POWER
 	CALL
 	INDEX
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.BIT_OR:          BIT_OR,
+	token.BIT_XOR:         BIT_XOR,
+	token.BIT_AND:         BIT_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.SHIFT_LEFT:      SHIFT,
+	token.SHIFT_RIGHT:     SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
 }
 
 type (
@@ -44,11 +72,28 @@ type Parser struct {
 	infixParseFns  map[token.TokenType]infixParseFn
 	curToken       token.Token
 	peekToken      token.Token
-	errors         []string
+	errors         []*ParseError
+
+	// comments holds COMMENT tokens that have been read but not yet
+	// attached to the statement following them.
+	comments []*ast.Comment
+	attached map[ast.Node][]*ast.Comment
+
+	// loopDepth counts the loops enclosing the current token within the
+	// current function, so break and continue can be checked.
+	loopDepth int
+
+	// closedBrace is the position of the last brace that closed a block,
+	// so an enclosing block does not claim it a second time.
+	closedBrace token.Position
+
+	// braceDepth counts the braces opened and not yet closed up to and
+	// including the current token.
+	braceDepth int
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l}
 
 	p.nextToken()
 	p.nextToken()
@@ -56,15 +101,21 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefix(token.FALSE, p.parseBooleanExpression)
 	p.registerPrefix(token.TRUE, p.parseBooleanExpression)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseString)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -75,24 +126,120 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	return p
 }
 
+// Errors returns the syntax errors formatted as "pos: message".
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		msgs[i] = err.Error()
+	}
+	return msgs
+}
+
+// ParseErrors returns the structured syntax errors in source order.
+func (p *Parser) ParseErrors() []*ParseError {
 	return p.errors
 }
 
+// addError records a syntax error found at tok. Only the first error at a
+// position is kept: when several parse functions give up on the same
+// token, the later errors are consequences of the first.
+func (p *Parser) addError(tok token.Token, expected []token.TokenType, format string, a ...interface{}) {
+	err := &ParseError{
+		Pos:      tok.Pos,
+		Expected: expected,
+		Found:    tok,
+		Msg:      fmt.Sprintf(format, a...),
+	}
+
+	for _, e := range p.errors {
+		if e.Pos == err.Pos {
+			return
+		}
+	}
+
+	p.errors = append(p.errors, err)
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, []token.TokenType{t}, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+}
+
+// errorAt reports whether the most recent error was found at tok.
+func (p *Parser) errorAt(tok token.Token) bool {
+	if len(p.errors) == 0 {
+		return false
+	}
+	return p.errors[len(p.errors)-1].Pos == tok.Pos
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.braceDepth++
+	case token.RBRACE:
+		p.braceDepth--
+	}
+
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+		p.peekToken = p.l.NextToken()
+	}
+}
+
+// takeComments removes and returns the pending comments that start before
+// pos.
+func (p *Parser) takeComments(pos token.Position) []*ast.Comment {
+	var taken, rest []*ast.Comment
+
+	for _, c := range p.comments {
+		if c.Pos().Offset < pos.Offset {
+			taken = append(taken, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+
+	p.comments = rest
+
+	return taken
+}
+
+func (p *Parser) attachComments(node ast.Node, comments []*ast.Comment) {
+	if len(comments) == 0 {
+		return
+	}
+
+	if p.attached == nil {
+		p.attached = make(map[ast.Node][]*ast.Comment)
+	}
+
+	p.attached[node] = append(p.attached[node], comments...)
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -100,22 +247,114 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-
-		program.Statements = append(program.Statements, stmt)
+		if stmt := p.parseStatementOrSync(); stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
 
 		p.nextToken()
 	}
 
+	p.attachComments(program, p.comments)
+	p.comments = nil
+	program.Comments = p.attached
+
 	return program
 }
 
+// statementKeywords are the tokens that can only start a statement, so
+// synchronize can safely resume parsing in front of them.
+var statementKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.FOR:      true,
+}
+
+// parseStatementOrSync parses one statement. If that produced errors the
+// statement is dropped and the parser is resynchronized at its end, so a
+// single mistake does not cascade into errors for every token that follows
+// it and the program keeps only the statements that parsed cleanly.
+func (p *Parser) parseStatementOrSync() ast.Statement {
+	depth := p.braceDepth
+	if p.curTokenIs(token.LBRACE) {
+		depth--
+	}
+
+	errs := len(p.errors)
+	comments := p.takeComments(p.curToken.Pos)
+	stmt := p.parseStatement()
+
+	if len(p.errors) > errs {
+		p.synchronize(depth)
+		return nil
+	}
+
+	p.attachComments(stmt, comments)
+
+	return stmt
+}
+
+// synchronize skips the rest of a statement that started at brace depth
+// depth. It stops when the current token is a semicolon or the next one is
+// a closing brace, a statement keyword or EOF, but not inside braces the
+// statement opened: the block of a construct that failed before reaching
+// it, as in "if (x { 1 }", is skipped up to and including its closing
+// brace.
+func (p *Parser) synchronize(depth int) {
+	for !p.curTokenIs(token.EOF) && p.braceDepth >= depth {
+		if p.braceDepth == depth {
+			if p.curTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || statementKeywords[p.peekToken.Type] {
+				return
+			}
+		}
+
+		if p.peekTokenIs(token.EOF) {
+			return
+		}
+
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.FOR:
+		if stmt := p.parseForStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.BREAK:
+		if stmt := p.parseBreakStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.CONTINUE:
+		if stmt := p.parseContinueStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.FUNCTION:
+		if !p.peekTokenIs(token.IDENT) {
+			return p.parseExpressionStatement()
+		}
+		if stmt := p.parseFunctionStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -129,13 +368,50 @@ func (p *Parser) parseString() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseIllegal reports a token the lexer could not make sense of. The
+// literal holds the offending source, which tells us what went wrong.
+func (p *Parser) parseIllegal() ast.Expression {
+	lit := p.curToken.Literal
+
+	switch {
+	case strings.HasPrefix(lit, "/*"):
+		p.addError(p.curToken, nil, "unterminated comment")
+	case strings.HasPrefix(lit, `"`) || strings.HasPrefix(lit, "`"):
+		p.addError(p.curToken, nil, "unterminated string literal")
+	case strings.HasPrefix(lit, `\`):
+		p.addError(p.curToken, nil, "invalid escape sequence %s in string literal", lit)
+	default:
+		p.addError(p.curToken, nil, "illegal character %q", lit)
+	}
+
+	return nil
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.BigIntLiteral{Token: p.curToken, Value: bigValue}
+		}
+	}
+	if err != nil {
+		p.addError(p.curToken, nil, "could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, nil, "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -149,47 +425,157 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		Token: p.curToken,
 	}
 
+	if !p.parseFunction(expression) {
+		return nil
+	}
+
+	return expression
+}
+
+// parseFunction parses the parameter list and body of fl, starting with
+// the left parenthesis as the peek token.
+func (p *Parser) parseFunction(fl *ast.FunctionLiteral) bool {
+	if !p.expectPeek(token.LPAREN) {
+		return false
+	}
+
+	fl.Parameters, fl.Defaults, fl.Rest = p.parseFunctionParameters()
+	if fl.Parameters == nil {
+		return false
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return false
+	}
+
+	fl.Body = p.parseFunctionBody()
+
+	return true
+}
+
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	expression := &ast.MacroLiteral{
+		Token: p.curToken,
+	}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 
-	expression.Parameters = p.parseFunctionParameters()
+	start := p.peekToken
+
+	var defaults []ast.Expression
+	var rest *ast.Identifier
+
+	expression.Parameters, defaults, rest = p.parseFunctionParameters()
+	if expression.Parameters == nil {
+		return nil
+	}
+
+	if defaults != nil || rest != nil {
+		p.addError(start, nil, "macro parameters cannot have default values or be rest parameters")
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
-	expression.Body = p.parseBlockStatement()
+	expression.Body = p.parseFunctionBody()
 
 	return expression
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionBody parses the body of a function or macro. Loops outside
+// the function do not extend into it.
+func (p *Parser) parseFunctionBody() *ast.BlockStatement {
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+
+	body := p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
+	return body
+}
+
+// parseFunctionParameters parses a parameter list such as
+// (a, b = 10, ...rest). Parameters with a default value must come after
+// those without one, and a rest parameter must be last. defaults is nil
+// when no parameter has a default. On error identifiers is nil.
+func (p *Parser) parseFunctionParameters() (identifiers []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier) {
+	identifiers = []*ast.Identifier{}
+	seen := make(map[string]bool)
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, nil, nil
 	}
 
-	p.nextToken()
+	for {
+		p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil, nil, nil
+			}
+
+			rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if seen[rest.Value] {
+				p.addError(rest.Token, nil, "duplicate parameter %s", rest.Value)
+				return nil, nil, nil
+			}
+
+			if p.peekTokenIs(token.COMMA) {
+				p.addError(p.peekToken, nil, "rest parameter must be last")
+				return nil, nil, nil
+			}
+			break
+		}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
+		if !p.curTokenIs(token.IDENT) {
+			p.addError(p.curToken, []token.TokenType{token.IDENT}, "expected parameter name, got %s instead", p.curToken.Type)
+			return nil, nil, nil
+		}
 
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[ident.Value] {
+			p.addError(ident.Token, nil, "duplicate parameter %s", ident.Value)
+			return nil, nil, nil
+		}
+		seen[ident.Value] = true
 		identifiers = append(identifiers, ident)
+
+		var value ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+
+			if value = p.parseExpression(ASSIGN); value == nil {
+				return nil, nil, nil
+			}
+		} else if defaults != nil {
+			p.addError(ident.Token, nil, "parameter %s without a default value follows one with a default value", ident.Value)
+			return nil, nil, nil
+		}
+
+		if value != nil && defaults == nil {
+			defaults = make([]ast.Expression, len(identifiers)-1)
+		}
+		if defaults != nil {
+			defaults = append(defaults, value)
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil, nil
 	}
 
-	return identifiers
+	return identifiers, defaults, rest
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -222,6 +608,29 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	stmt := &ast.FunctionStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	stmt.Function = &ast.FunctionLiteral{Token: stmt.Token, Name: stmt.Name.Value}
+
+	if !p.parseFunction(stmt.Function) {
+		return nil
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -243,6 +652,115 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	stmt.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.loopDepth++
+	stmt.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.addError(p.curToken, nil, "break outside loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.addError(p.curToken, nil, "continue outside loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -256,8 +774,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, nil, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -332,12 +849,41 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.curPrecedence()
+
+	// ** is right-associative: parsing its right operand one level lower
+	// lets a following ** bind to that operand first.
+	if expression.Token.Type == token.POWER {
+		precedence--
+	}
+
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
 	return expression
 }
 
+// parseAssignExpression parses the right-hand side of an assignment. It is
+// right-associative, so "a = b = 1" assigns 1 to b and then to a.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.addError(p.curToken, nil, "cannot assign to %s", target.String())
+		return nil
+	}
+
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   target,
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
 func (p *Parser) parseBooleanExpression() ast.Expression {
 	expression := &ast.Boolean{
 		Token: p.curToken,
@@ -362,24 +908,56 @@ func (p *Parser) parseGroupExpression() ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbrack = p.curToken
 	return array
 }
 
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Pairs = []ast.HashPair{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	hash.Rbrace = p.curToken
+
+	return hash
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 
-	if p.peekTokenIs(token.RBRACKET) {
+	if p.peekTokenIs(end) {
 		p.nextToken()
 		return list
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseListElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseListElement())
 	}
 
 	if !p.expectPeek(end) {
@@ -389,6 +967,21 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseListElement parses an element of an array literal or an argument of
+// a call, either of which may be spread with "...".
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+
+	return spread
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
@@ -429,11 +1022,23 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		block.Statements = append(block.Statements, stmt)
+		if stmt := p.parseStatementOrSync(); stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+
+		// An error on the closing brace itself, as in "{ 1 + }", leaves the
+		// parser sitting on it; stepping past it would swallow the rest of
+		// the enclosing code into this block.
+		if p.curTokenIs(token.RBRACE) && p.errorAt(p.curToken) && p.curToken.Pos != p.closedBrace {
+			break
+		}
 
 		p.nextToken()
 	}
+
+	block.Rbrace = p.curToken
+	p.closedBrace = p.curToken.Pos
+
 	return block
 }
 
@@ -441,6 +1046,33 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
+
+	return exp
+}
+
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+// parseImportExpression parses `import "path"`. The path has to be a
+// string literal so that the compiler knows it.
+func (p *Parser) parseImportExpression() ast.Expression {
+	exp := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	exp.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 
 	return exp
 }
@@ -451,10 +1083,15 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	p.nextToken()
 
 	exp.Index = p.parseExpression(LOWEST)
+	if exp.Index == nil {
+		return nil
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
 
+	exp.Rbrack = p.curToken
+
 	return exp
 }
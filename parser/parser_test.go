@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"gomonkey/ast"
 	"gomonkey/lexer"
+	"gomonkey/token"
 	"reflect"
 	"testing"
 )
@@ -138,6 +139,102 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testIntegerLiteral(t, stmt.Expression, 5)
 }
 
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0x1F", 31},
+		{"0XFF", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0xFF_FF", 65535},
+		{"0b_1", 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		checkProgram(t, program, 1)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("wrong value for %q. want=%d, got=%d", tt.input, tt.expected, literal.Value)
+		}
+	}
+
+	for _, input := range []string{"0b102", "0x", "1__0", "1_", "0o8"} {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		expected := fmt.Sprintf("1:1: could not parse %q as integer", input)
+		if errors := p.Errors(); len(errors) != 1 || errors[0] != expected {
+			t.Errorf("wrong errors for %q. want=%q, got=%q", input, expected, errors)
+		}
+	}
+}
+
+func TestBigIntLiteralExpression(t *testing.T) {
+	input := "18446744073709551616;"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	checkProgram(t, program, 1)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+	literal, ok := stmt.Expression.(*ast.BigIntLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.BigIntLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value.String() != "18446744073709551616" {
+		t.Errorf("literal.Value not %s. got=%s", "18446744073709551616", literal.Value)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "1.5e2;"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	checkProgram(t, program, 1)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != 150 {
+		t.Errorf("literal.Value not %g. got=%g", 150.0, literal.Value)
+	}
+
+	if literal.TokenLiteral() != "1.5e2" {
+		t.Errorf("literal.TokenLiteral not %q. got=%q", "1.5e2", literal.TokenLiteral())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		value    interface{}
@@ -256,6 +353,70 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 + 4 * 5 == 3 * 1 + 4 * 5",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
 		},
+		{
+			"a <= b == b >= a",
+			"((a <= b) == (b >= a))",
+		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
+		{
+			"a * b ** -c",
+			"(a * (b ** (-c)))",
+		},
+		{
+			"a ** b[0]",
+			"(a ** (b[0]))",
+		},
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b == c",
+			"(a & (b == c))",
+		},
+		{
+			"1 << 2 + 3 < x",
+			"((1 << (2 + 3)) < x)",
+		},
+		{
+			"a >> 1 & 0xF",
+			"((a >> 1) & 0xF)",
+		},
+		{
+			"~a & ~-b",
+			"((~a) & (~(-b)))",
+		},
+		{
+			"a && b | c",
+			"(a && (b | c))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a < b && !c == d",
+			"((a < b) && ((!c) == d))",
+		},
+		{
+			"x = a || b",
+			"(x = (a || b))",
+		},
 		{
 			"true",
 			"true",
@@ -320,6 +481,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"add() + []",
+			"(add() + [])",
+		},
+		{
+			"-m.f(1).x[0] * 2",
+			"((-(((m.f)(1).x)[0])) * 2)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -337,6 +506,68 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = y = 1 + 2", "(x = (y = (1 + 2)))"},
+		{"x += 2 * 3", "(x += (2 * 3))"},
+		{"x -= 1; x *= 2; x /= 3", "(x -= 1)(x *= 2)(x /= 3)"},
+		{"a[i + 1] = b[0]", "((a[(i + 1)]) = (b[0]))"},
+		{"h[\"k\"] += 1", "((h[k]) += 1)"},
+		{"f(x = 1)", "f((x = 1))"},
+		{"x == y = 1", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if tt.expected == "" {
+			if len(p.Errors()) == 0 {
+				t.Errorf("expected parser errors for %q", tt.input)
+			}
+			continue
+		}
+
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2", "1:3: cannot assign to 1"},
+		{"f() += 1", "1:5: cannot assign to f()"},
+		{"(a + b) = c", "1:9: cannot assign to (a + b)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("wrong number of errors for %q. want=1, got=%d (%q)", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	type cond struct {
 		Left  interface{}
@@ -418,78 +649,105 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
-func TestFunctionLiteralParsing(t *testing.T) {
-	input := `fn(x, y) { x + y; }`
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { if (x == 5) { break; } continue }`
 
 	l := lexer.New(input)
 	p := New(l)
-
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
 	checkProgram(t, program, 1)
 
-	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
 	if !ok {
-		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
 	}
 
-	function, ok := stmt.Expression.(*ast.FunctionLiteral)
-	if !ok {
-		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
 	}
 
-	if lp := len(function.Parameters); lp != 2 {
-		t.Fatalf("function literal parameters wrong. want 2, got=%d", lp)
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body does not contain 2 statements. got=%d", len(stmt.Body.Statements))
 	}
 
-	testLiteralExpression(t, function.Parameters[0], "x")
-	testLiteralExpression(t, function.Parameters[1], "y")
-
-	if lb := len(function.Body.Statements); lb != 1 {
-		t.Fatalf("function.Body.Statements has not 1 statement. got=%d", lb)
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[1] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[1])
 	}
 
-	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
-	if !ok {
-		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	expected := "while (x < 10) if (x == 5) break;continue;"
+	if program.String() != expected {
+		t.Errorf("wrong String(). want=%q, got=%q", expected, program.String())
 	}
-
-	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
-func TestFunctionParameterParsing(t *testing.T) {
+func TestForStatement(t *testing.T) {
 	tests := []struct {
-		input          string
-		expectedParams []string
+		input         string
+		expectedKey   string
+		expectedValue string
+		expected      string
 	}{
-		{input: "fn() {};", expectedParams: []string{}},
-		{input: "fn(x) {};", expectedParams: []string{"x"}},
-		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+		{"for (x in [1, 2]) { x }", "", "x", "for (x in [1, 2]) x"},
+		{"for (i, ch in \"abc\") { break }", "i", "ch", "for (i, ch in abc) break;"},
 	}
 
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l)
-
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
 		checkProgram(t, program, 1)
 
-		stmt := program.Statements[0].(*ast.ExpressionStatement)
-		function := stmt.Expression.(*ast.FunctionLiteral)
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+		}
 
-		if len(function.Parameters) != len(tt.expectedParams) {
-			t.Errorf("length parameters wrong, want %d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		if tt.expectedKey == "" && stmt.Key != nil {
+			t.Errorf("expected no key. got=%s", stmt.Key)
+		}
+		if tt.expectedKey != "" {
+			testIdentifier(t, stmt.Key, tt.expectedKey)
 		}
+		testIdentifier(t, stmt.Value, tt.expectedValue)
 
-		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
+		if program.String() != tt.expected {
+			t.Errorf("wrong String(). want=%q, got=%q", tt.expected, program.String())
 		}
 	}
 }
 
-func TestCallExpressionParsing(t *testing.T) {
-	input := "add(1, 2 * 3, 4 + 5);"
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside loop"},
+		{"if (true) { continue; }", "1:13: continue outside loop"},
+		{"while (true) { fn() { break; } }", "1:23: break outside loop"},
+		{"for (x in y) { }; continue", "1:19: continue outside loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("wrong number of errors for %q. want=1, got=%d (%q)", tt.input, len(errors), errors)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
 
 	l := lexer.New(input)
 	p := New(l)
@@ -500,80 +758,306 @@ func TestCallExpressionParsing(t *testing.T) {
 
 	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
 	if !ok {
-		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
 	}
 
-	exp, ok := stmt.Expression.(*ast.CallExpression)
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
 	if !ok {
-		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
 	}
 
-	if !testIdentifier(t, exp.Function, "add") {
-		return
+	if lp := len(function.Parameters); lp != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", lp)
 	}
 
-	if la := len(exp.Arguments); la != 3 {
-		t.Fatalf("wrong length of arguments. got=%d", la)
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	if lb := len(function.Body.Statements); lb != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statement. got=%d", lb)
 	}
 
-	testLiteralExpression(t, exp.Arguments[0], 1)
-	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
-	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
-func TestStringLiteralExpression(t *testing.T) {
-	input := `"hello world";`
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
 
 	l := lexer.New(input)
 	p := New(l)
-
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
+	checkProgram(t, program, 1)
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+	}
 
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
 	if !ok {
-		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
 	}
 
-	if literal.Value != "hello world" {
-		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	if function.Name != "myFunction" {
+		t.Fatalf("function literal name wrong. want 'myFunction', got=%q", function.Name)
 	}
 }
 
-func TestParsingArrayLiterals(t *testing.T) {
-	input := "[1, 2 * 2, 3 + 3]"
+func TestFunctionStatement(t *testing.T) {
+	input := `fn add(a, b = 1) { a + b }`
 
 	l := lexer.New(input)
 	p := New(l)
-
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
+	checkProgram(t, program, 1)
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	array, ok := stmt.Expression.(*ast.ArrayLiteral)
-
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
 	if !ok {
-		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmt.Expression)
+		t.Fatalf("program.Statements[0] is not ast.FunctionStatement. got=%T", program.Statements[0])
 	}
 
-	if len(array.Elements) != 3 {
-		t.Fatalf("len(array.Elements) not 3. got=%q", len(array.Elements))
+	if !testIdentifier(t, stmt.Name, "add") {
+		return
 	}
 
-	testIntegerLiteral(t, array.Elements[0], 1)
-	testInfixExpression(t, array.Elements[1], 2, "*", 2)
-	testInfixExpression(t, array.Elements[2], 3, "+", 3)
-}
+	if stmt.Function.Name != "add" {
+		t.Fatalf("function literal name wrong. want 'add', got=%q", stmt.Function.Name)
+	}
 
-func TestParsingIndexExpressions(t *testing.T) {
-	input := "myArray[1 + 1]"
+	if len(stmt.Function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(stmt.Function.Parameters))
+	}
 
-	l := lexer.New(input)
-	p := New(l)
+	if stmt.String() != "fn add(a, b = 1)(a + b)" {
+		t.Fatalf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
 
-	program := p.ParseProgram()
+func TestFunctionStatementOrLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn f() { 1 } fn g() { 2 }", "fn f()1fn g()2"},
+		{"fn(x) { x }(5)", "fn(x)x(5)"},
+		{"fn f() { fn g() { 1 } }", "fn f()fn g()1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		checkProgram(t, program, 1)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong, want %d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 10) {}", "fn(a, b = 10)"},
+		{"fn(a = 1, b = a * 2) {}", "fn(a = 1, b = (a * 2))"},
+		{"fn(...rest) {}", "fn(...rest)"},
+		{"fn(a, b = 1, ...rest) {}", "fn(a, b = 1, ...rest)"},
+		{"fn(f = fn(x = 1) { x }) {}", "fn(f = fn(x = 1)x)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestInvalidFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...rest, a) {}", "1:11: rest parameter must be last"},
+		{"fn(a = 1, b) {}", "1:11: parameter b without a default value follows one with a default value"},
+		{"fn(1) {}", "1:4: expected parameter name, got INT instead"},
+		{"fn(a, ...r, b) { a }", "1:11: rest parameter must be last"},
+		{"fn(a, a) { a }", "1:7: duplicate parameter a"},
+		{"fn(a, b = 1, ...a) { a }", "1:17: duplicate parameter a"},
+		{"fn f(x, y, x) { x }", "1:12: duplicate parameter x"},
+		{"macro(a = 1) { a }", "1:7: macro parameters cannot have default values or be rest parameters"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected one error for %q. got=%q", tt.input, errors)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(...args)", "f(...args)"},
+		{"f(1, ...a, ...b)", "f(1, ...a, ...b)"},
+		{"[0, ...xs, 1 + 2]", "[0, ...xs, (1 + 2)]"},
+		{"[...f(x)[0]]", "[...(f(x)[0])]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	checkProgram(t, program, 1)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+
+	if la := len(exp.Arguments); la != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", la)
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+
+	if !ok {
+		t.Fatalf("exp not *ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%q", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
 	checkParserErrors(t, p)
 
 	stmt := program.Statements[0].(*ast.ExpressionStatement)
@@ -592,6 +1076,464 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingMemberExpressions(t *testing.T) {
+	input := "math.pi"
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	memberExp, ok := stmt.Expression.(*ast.MemberExpression)
+
+	if !ok {
+		t.Fatalf("exp not *ast.MemberExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, memberExp.Object, "math") {
+		return
+	}
+
+	testIdentifier(t, memberExp.Member, "pi")
+}
+
+func TestParsingImportExpressions(t *testing.T) {
+	input := `let m = import "lib/strings";`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	importExp, ok := stmt.Value.(*ast.ImportExpression)
+
+	if !ok {
+		t.Fatalf("exp not *ast.ImportExpression. got=%T", stmt.Value)
+	}
+
+	if importExp.Path.Value != "lib/strings" {
+		t.Errorf("wrong path. got=%q", importExp.Path.Value)
+	}
+
+	if importExp.String() != `import "lib/strings"` {
+		t.Errorf("wrong String(). got=%q", importExp.String())
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	tests := []struct {
+		expected map[string]func(ast.Expression)
+		input    string
+	}{
+		{
+			input: `{"one": 1, "two": 2, "three": 3}`,
+			expected: map[string]func(ast.Expression){
+				"one":   func(e ast.Expression) { testIntegerLiteral(t, e, 1) },
+				"two":   func(e ast.Expression) { testIntegerLiteral(t, e, 2) },
+				"three": func(e ast.Expression) { testIntegerLiteral(t, e, 3) },
+			},
+		},
+		{
+			input:    "{}",
+			expected: map[string]func(ast.Expression){},
+		},
+		{
+			input: `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`,
+			expected: map[string]func(ast.Expression){
+				"one":   func(e ast.Expression) { testInfixExpression(t, e, 0, "+", 1) },
+				"two":   func(e ast.Expression) { testInfixExpression(t, e, 10, "-", 8) },
+				"three": func(e ast.Expression) { testInfixExpression(t, e, 15, "/", 5) },
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		hash, ok := stmt.Expression.(*ast.HashLiteral)
+
+		if !ok {
+			t.Fatalf("exp not *ast.HashLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(hash.Pairs) != len(tt.expected) {
+			t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+		}
+
+		for _, pair := range hash.Pairs {
+			literal, ok := pair.Key.(*ast.StringLiteral)
+			if !ok {
+				t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+				continue
+			}
+
+			testFunc, ok := tt.expected[literal.String()]
+			if !ok {
+				t.Errorf("no test function for key %q found", literal.String())
+				continue
+			}
+
+			testFunc(pair.Value)
+		}
+	}
+}
+
+func TestParsingHashLiteralsWithMixedKeys(t *testing.T) {
+	input := `{"k": v, 1: x, true: y}`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+
+	if !ok {
+		t.Fatalf("exp not *ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	if key, ok := hash.Pairs[0].Key.(*ast.StringLiteral); !ok || key.Value != "k" {
+		t.Errorf("hash.Pairs[0].Key is not \"k\". got=%T(%s)", hash.Pairs[0].Key, hash.Pairs[0].Key)
+	}
+
+	testIntegerLiteral(t, hash.Pairs[1].Key, 1)
+	testBooleanLiteral(t, hash.Pairs[2].Key, true)
+
+	testIdentifier(t, hash.Pairs[0].Value, "v")
+	testIdentifier(t, hash.Pairs[1].Value, "x")
+	testIdentifier(t, hash.Pairs[2].Value, "y")
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	checkProgram(t, program, 1)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if lp := len(macro.Parameters); lp != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d", lp)
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if lb := len(macro.Body.Statements); lb != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statement. got=%d", lb)
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	input := "let x = 5;\nlet = 10;"
+
+	l := lexer.New(input, lexer.WithFilename("main.mk"))
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+
+	expected := "main.mk:2:5: expected next token to be IDENT, got = instead"
+	if errors[0] != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+		expectedAST    string
+	}{
+		{
+			"let = 10; let x 5; let y = 3; y",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"1:17: expected next token to be =, got INT instead",
+			},
+			"let y = 3;y",
+		},
+		{
+			"5 + * 2; )));\nlet a = 1;",
+			[]string{
+				"1:5: no prefix parse function for * found",
+				"1:10: no prefix parse function for ) found",
+			},
+			"let a = 1;",
+		},
+		{
+			"let f = fn() { let = 1; if (x) { 1 + } };\nlet b = 2;\nb",
+			[]string{
+				"1:20: expected next token to be IDENT, got = instead",
+				"1:38: no prefix parse function for } found",
+			},
+			"let b = 2;b",
+		},
+		{
+			"[1, 2",
+			[]string{"1:6: expected next token to be ], got EOF instead"},
+			"",
+		},
+		{
+			"if (x { 1 }; let b = 2; b",
+			[]string{"1:7: expected next token to be ), got { instead"},
+			"let b = 2;b",
+		},
+		{
+			"let f = fn() { if (x { 1 } };\nf",
+			[]string{"1:22: expected next token to be ), got { instead"},
+			"f",
+		},
+		{
+			"fn(a, 1) { a }; let b = 2; b",
+			[]string{"1:7: expected parameter name, got INT instead"},
+			"let b = 2;b",
+		},
+		{
+			"{1: 2, 3}; let b = 2; b",
+			[]string{"1:9: expected next token to be :, got } instead"},
+			"let b = 2;b",
+		},
+		{
+			"let a = [1]; a[",
+			[]string{"1:16: no prefix parse function for EOF found"},
+			"let a = [1];",
+		},
+		{
+			"let f = fn(a = 1, b) { a };\nlet g = fn(a, ...r, b) { a };\nlet b = 2; b",
+			[]string{
+				"1:19: parameter b without a default value follows one with a default value",
+				"2:19: rest parameter must be last",
+			},
+			"let b = 2;b",
+		},
+		{
+			"a[; let b = 2; b",
+			[]string{"1:3: no prefix parse function for ; found"},
+			"let b = 2;b",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. want=%d, got=%d (%q)",
+				tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+
+		for i, want := range tt.expectedErrors {
+			if errors[i] != want {
+				t.Errorf("wrong error %d for %q. want=%q, got=%q", i, tt.input, want, errors[i])
+			}
+		}
+
+		if program.String() != tt.expectedAST {
+			t.Errorf("wrong partial AST for %q. want=%q, got=%q", tt.input, tt.expectedAST, program.String())
+		}
+	}
+}
+
+func TestIllegalTokenErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let s = \"abc;\nlet t = 1;", "1:9: unterminated string literal"},
+		{"let s = `abc", "1:9: unterminated string literal"},
+		{`let s = "a\qb";`, "1:9: invalid escape sequence \\q in string literal"},
+		{"let x = 1 @ 2;", "1:11: illegal character \"@\""},
+		{"let x = 1; /* oops", "1:12: unterminated comment"},
+		{"import lib", "1:8: expected next token to be STRING, got IDENT instead"},
+		{"m.1", "1:3: expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestStructuredParseErrors(t *testing.T) {
+	l := lexer.New("let x 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	errs := p.ParseErrors()
+	if len(errs) != 1 {
+		t.Fatalf("wrong number of errors. want=1, got=%d", len(errs))
+	}
+
+	err := errs[0]
+	if err.Pos.Line != 1 || err.Pos.Column != 7 {
+		t.Errorf("wrong position. got=%s", err.Pos)
+	}
+	if len(err.Expected) != 1 || err.Expected[0] != token.ASSIGN {
+		t.Errorf("wrong expected set. got=%v", err.Expected)
+	}
+	if err.Found.Type != token.INT || err.Found.Literal != "5" {
+		t.Errorf("wrong found token. got=%+v", err.Found)
+	}
+}
+
+func TestParserErrorsAreDeduplicated(t *testing.T) {
+	// Each nested group gives up on the same missing parenthesis.
+	l := lexer.New("((1")
+	p := New(l)
+	p.ParseProgram()
+
+	errs := p.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("wrong number of errors. want=1, got=%d (%q)", len(errs), errs)
+	}
+
+	expected := "1:4: expected next token to be ), got EOF instead"
+	if errs[0] != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, errs[0])
+	}
+}
+
+func TestCommentsAreAttachedToFollowingStatement(t *testing.T) {
+	input := `// add sums two numbers.
+let add = fn(a, b) {
+	/* the body */
+	a + b
+};
+add(1, /* inline */ 2); // call it
+// the end`
+
+	l := lexer.New(input, lexer.WithComments())
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	checkProgram(t, program, 2)
+
+	letStmt := program.Statements[0].(*ast.LetStatement)
+	body := letStmt.Value.(*ast.FunctionLiteral).Body
+
+	tests := []struct {
+		node     ast.Node
+		expected []string
+	}{
+		{letStmt, []string{" add sums two numbers."}},
+		{body.Statements[0], []string{" the body "}},
+		{program.Statements[1], nil},
+		{program, []string{" inline ", " call it", " the end"}},
+	}
+
+	for i, tt := range tests {
+		comments := program.Comments[tt.node]
+
+		if len(comments) != len(tt.expected) {
+			t.Errorf("tests[%d] - wrong number of comments. want=%d, got=%d", i, len(tt.expected), len(comments))
+			continue
+		}
+
+		for j, c := range comments {
+			if c.Text() != tt.expected[j] {
+				t.Errorf("tests[%d] - wrong comment %d. want=%q, got=%q", i, j, tt.expected[j], c.Text())
+			}
+		}
+	}
+}
+
+func TestCommentsAreSkippedByDefault(t *testing.T) {
+	l := lexer.New("let x = 1; // one\n/* two */ x")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	checkProgram(t, program, 2)
+
+	if program.Comments != nil {
+		t.Errorf("expected no comments. got=%v", program.Comments)
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"foo", "1:1-1:4"},
+		{"let answer = 6 * 7;", "1:1-1:19"},
+		{"  -a + b", "1:3-1:9"},
+		{"add(1,\n  2)", "1:1-2:5"},
+		{"arr[1]", "1:1-1:7"},
+		{"[1, 2]", "1:1-1:7"},
+		{`{"a": 1}`, "1:1-1:9"},
+		{"if (x) { y } else { z }", "1:1-1:24"},
+		{"fn(x) {\n  x\n}", "1:1-3:2"},
+		{"return x", "1:1-1:9"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		pos, end := program.Statements[0].Pos(), program.Statements[0].End()
+		actual := fmt.Sprintf("%d:%d-%d:%d", pos.Line, pos.Column, end.Line, end.Column)
+
+		if actual != tt.expected {
+			t.Errorf("wrong node span for %q. want=%s, got=%s", tt.input, tt.expected, actual)
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
@@ -2,19 +2,42 @@ package repl
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"gomonkey/ast"
+	"gomonkey/compiler"
 	"gomonkey/evaluator"
 	"gomonkey/lexer"
 	"gomonkey/object"
 	"gomonkey/parser"
+	"gomonkey/vm"
 	"io"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+const (
+	EngineEval = "eval"
+	EngineVM   = "vm"
+)
+
+func Start(in io.Reader, out io.Writer, engine string) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+
+	// Modules are imported from the working directory. The engines keep
+	// separate caches since their modules are not interchangeable.
+	resolver := object.NewDirResolver(".")
+	env.SetModules(object.NewModules(resolver, nil))
+	vmModules := object.NewModules(resolver, nil)
+
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, def := range object.Builtins {
+		symbolTable.DefineBuiltin(i, def.Name)
+	}
 
 	for {
 		fmt.Print(PROMPT)
@@ -34,7 +57,22 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacros(program, macroEnv)
+		if err != nil {
+			io.WriteString(out, "ERROR: "+err.Error()+"\n")
+			continue
+		}
+
+		var evaluated object.Object
+		if engine == EngineVM {
+			comp := compiler.NewWithState(symbolTable, constants)
+			evaluated = runBytecode(comp, expanded, globals, vmModules)
+			constants = comp.Bytecode().Constants
+		} else {
+			evaluated = evaluator.Eval(expanded, env)
+		}
+
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -42,6 +80,29 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
+func runBytecode(comp *compiler.Compiler, program ast.Node, globals []object.Object, modules *object.Modules) object.Object {
+	if err := comp.Compile(program); err != nil {
+		return errorObject(err)
+	}
+
+	machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+	machine.SetModules(modules)
+	if err := machine.Run(); err != nil {
+		return errorObject(err)
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+func errorObject(err error) *object.Error {
+	var errObj *object.Error
+	if errors.As(err, &errObj) {
+		return errObj
+	}
+
+	return &object.Error{Message: err.Error()}
+}
+
 func printParseErrors(out io.Writer, errors []string) {
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
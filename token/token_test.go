@@ -0,0 +1,21 @@
+package token
+
+import "testing"
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos      Position
+		expected string
+	}{
+		{Position{}, "-"},
+		{Position{Filename: "main.mk"}, "main.mk"},
+		{Position{Line: 3, Column: 7}, "3:7"},
+		{Position{Filename: "main.mk", Offset: 20, Line: 3, Column: 7}, "main.mk:3:7"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.pos.String(); got != tt.expected {
+			t.Errorf("wrong position string. want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package vm
+
+import (
+	"gomonkey/compiler"
+	"gomonkey/lexer"
+	"gomonkey/object"
+	"gomonkey/parser"
+)
+
+func (vm *VM) importModule(path string) (*object.Module, error) {
+	if vm.modules == nil {
+		return nil, newError("cannot import %q: no module resolver", path)
+	}
+
+	module, err := vm.modules.Load(path, vm.runModule)
+	if err != nil {
+		if errObj, ok := err.(*object.Error); ok {
+			return nil, errObj
+		}
+
+		return nil, newError("%s", err)
+	}
+
+	return module, nil
+}
+
+// runModule compiles the source of a module and runs it on a VM of its
+// own, whose globals become the module's exports.
+func (vm *VM) runModule(name, src string) (*object.Module, error) {
+	p := parser.New(lexer.New(src, lexer.WithFilename(name)))
+	program := p.ParseProgram()
+
+	if parseErrors := p.ParseErrors(); len(parseErrors) != 0 {
+		return nil, &object.Error{Message: parseErrors[0].Msg, Pos: parseErrors[0].Pos}
+	}
+
+	symbolTable := compiler.NewSymbolTable()
+	for i, def := range object.Builtins {
+		symbolTable.DefineBuiltin(i, def.Name)
+	}
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	machine := New(comp.Bytecode())
+	machine.modules = vm.modules
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+
+	globals := make(map[string]int)
+	for _, symbol := range symbolTable.Globals() {
+		globals[symbol.Name] = symbol.Index
+	}
+
+	return object.NewModule(name, func(binding string) (object.Object, bool) {
+		index, ok := globals[binding]
+		if !ok {
+			return nil, false
+		}
+
+		if value := machine.globals[index]; value != nil {
+			return value, true
+		}
+
+		return Null, true
+	}), nil
+}
@@ -0,0 +1,951 @@
+package vm
+
+import (
+	"fmt"
+	"gomonkey/code"
+	"gomonkey/compiler"
+	"gomonkey/object"
+	"gomonkey/token"
+	"math"
+	"math/big"
+)
+
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+	MaxFrames   = 1024
+)
+
+var (
+	True  = object.True
+	False = object.False
+	Null  = &object.Null{}
+)
+
+var infixOperators = map[code.Opcode]token.TokenType{
+	code.OpAdd:          token.PLUS,
+	code.OpSub:          token.MINUS,
+	code.OpMul:          token.ASTERISK,
+	code.OpDiv:          token.SLASH,
+	code.OpMod:          token.PERCENT,
+	code.OpPow:          token.POWER,
+	code.OpGreaterThan:  token.GT,
+	code.OpLessThan:     token.LT,
+	code.OpGreaterEqual: token.GT_EQ,
+	code.OpLessEqual:    token.LT_EQ,
+	code.OpEqual:        token.EQ,
+	code.OpNotEqual:     token.NOT_EQ,
+	code.OpBitAnd:       token.BIT_AND,
+	code.OpBitOr:        token.BIT_OR,
+	code.OpBitXor:       token.BIT_XOR,
+	code.OpShiftLeft:    token.SHIFT_LEFT,
+	code.OpShiftRight:   token.SHIFT_RIGHT,
+}
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int
+
+	globals []object.Object
+
+	frames      []*Frame
+	framesIndex int
+
+	modules *object.Modules
+	budget  *object.Budget
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		SourceMap:    bytecode.SourceMap,
+		NumLocals:    bytecode.NumLocals,
+	}
+	unit := &object.Unit{Constants: bytecode.Constants, Globals: make([]object.Object, GlobalsSize)}
+	mainClosure := &object.Closure{Fn: mainFn, Unit: unit}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants: unit.Constants,
+
+		stack: make([]object.Object, StackSize),
+		sp:    mainFn.NumLocals,
+
+		globals: unit.Globals,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	vm.currentFrame().cl.Unit.Globals = s
+
+	return vm
+}
+
+// SetModules lets the program import the modules m loads.
+func (vm *VM) SetModules(m *object.Modules) {
+	vm.modules = m
+}
+
+// SetBudget makes the program fail with a limit error once budget runs out
+// of steps or its context is done. Every instruction counts as a step, as
+// does every value a spread expression expands. Call depth is bounded by
+// MaxFrames rather than by the budget.
+func (vm *VM) SetBudget(budget *object.Budget) {
+	vm.budget = budget
+}
+
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// Run executes the bytecode. Runtime errors are returned as *object.Error
+// carrying the same message and position the evaluator would produce.
+func (vm *VM) Run() error {
+	return vm.locate(vm.run(0))
+}
+
+// Call calls fn, a closure or builtin, with args and returns its result.
+// Host code uses it to call back into functions a program handed it once
+// Run has returned, and builtins use it through their object.CallContext
+// while Run is executing. It leaves the stack as it found it.
+func (vm *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	sp, framesIndex := vm.sp, vm.framesIndex
+
+	err := vm.call(fn, args)
+	if err != nil {
+		err = vm.locate(err)
+		vm.sp, vm.framesIndex = sp, framesIndex
+		vm.enterUnit(vm.currentFrame().cl.Unit)
+		return nil, err
+	}
+
+	result := vm.pop()
+	vm.sp = sp
+
+	return result, nil
+}
+
+func (vm *VM) call(fn object.Object, args []object.Object) error {
+	if vm.sp+1+len(args) >= StackSize {
+		return newError("stack overflow")
+	}
+
+	vm.stack[vm.sp] = fn
+	copy(vm.stack[vm.sp+1:], args)
+	vm.sp += 1 + len(args)
+
+	framesIndex := vm.framesIndex
+	if err := vm.executeCall(len(args)); err != nil {
+		return err
+	}
+
+	// A closure has pushed a frame, which returns by popping it.
+	return vm.run(framesIndex)
+}
+
+// locate sets the position of a runtime error that does not have one yet.
+func (vm *VM) locate(err error) error {
+	if errObj, ok := err.(*object.Error); ok && !errObj.Pos.IsValid() {
+		// Errors are raised before a new frame is pushed, so the current
+		// frame still points at the failing instruction.
+		frame := vm.currentFrame()
+		errObj.Pos = frame.cl.Fn.SourceMap.Lookup(frame.ip)
+	}
+
+	return err
+}
+
+// run executes instructions until the frames above depth have returned,
+// or until the main frame ends when depth is 0.
+func (vm *VM) run(depth int) error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.framesIndex > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		if err := vm.budget.Step(); err != nil {
+			return err
+		}
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+		case code.OpPop:
+			vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
+			if err := vm.executeInfixOperation(op); err != nil {
+				return err
+			}
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return err
+			}
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return err
+			}
+		case code.OpNull:
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+		case code.OpBang:
+			operand := vm.pop()
+
+			if err := vm.push(nativeBoolToBooleanObject(!isTruthy(operand))); err != nil {
+				return err
+			}
+		case code.OpMinus:
+			if err := vm.executeMinusOperator(); err != nil {
+				return err
+			}
+		case code.OpBitNot:
+			if err := vm.executeBitNotOperator(); err != nil {
+				return err
+			}
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpIfFalsyOrPop, code.OpJumpIfTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			// The deciding operand is the result, so it stays on the stack
+			// when the jump is taken.
+			if isTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpIfTruthyOrPop) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			// A hoisted function can run before a global it refers to
+			// has been assigned; that global reads as null, like a local.
+			global := vm.globals[globalIndex]
+			if global == nil {
+				global = Null
+			}
+
+			if err := vm.push(global); err != nil {
+				return err
+			}
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			slot := &vm.stack[frame.basePointer+int(localIndex)]
+			if cell, ok := (*slot).(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				*slot = vm.pop()
+			}
+		case code.OpDefineLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			// A new binding replaces a cell left in the slot by an earlier
+			// run of the block instead of writing through it.
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			if err := vm.push(deref(vm.stack[frame.basePointer+int(localIndex)])); err != nil {
+				return err
+			}
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			// The first capture moves the local into a cell; the frame then
+			// reads and writes it through OpGetLocal and OpSetLocal.
+			frame := vm.currentFrame()
+			slot := &vm.stack[frame.basePointer+int(localIndex)]
+			cell, ok := (*slot).(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Value: *slot}
+				*slot = cell
+			}
+
+			if err := vm.push(cell); err != nil {
+				return err
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			definition := object.Builtins[builtinIndex]
+			if err := vm.push(definition.Builtin); err != nil {
+				return err
+			}
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(deref(currentClosure.Free[freeIndex])); err != nil {
+				return err
+			}
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			if cell, ok := currentClosure.Free[freeIndex].(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				currentClosure.Free[freeIndex] = vm.pop()
+			}
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure.Free[freeIndex]); err != nil {
+				return err
+			}
+		case code.OpCurrentClosure:
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure); err != nil {
+				return err
+			}
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(array); err != nil {
+				return err
+			}
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			module, err := vm.importModule(vm.constants[constIndex].(*object.String).Value)
+			if err != nil {
+				return err
+			}
+
+			if err := vm.push(module); err != nil {
+				return err
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeIndexExpression(left, index); err != nil {
+				return err
+			}
+		case code.OpDupPair:
+			first, second := vm.stack[vm.sp-2], vm.stack[vm.sp-1]
+
+			if err := vm.push(first); err != nil {
+				return err
+			}
+			if err := vm.push(second); err != nil {
+				return err
+			}
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeSetIndex(left, index, value); err != nil {
+				return err
+			}
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+		case code.OpCallSpread:
+			args := vm.pop().(*object.Array)
+
+			for _, arg := range args.Elements {
+				if err := vm.push(arg); err != nil {
+					return err
+				}
+			}
+
+			if err := vm.executeCall(len(args.Elements)); err != nil {
+				return err
+			}
+		case code.OpJumpIfArg:
+			argIndex := int(code.ReadUint8(ins[ip+1:]))
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			if vm.currentFrame().numArgs > argIndex {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpSpread:
+			obj := vm.pop()
+
+			iterable, ok := obj.(object.Iterable)
+			if !ok {
+				return newError("not iterable: %s", obj.Type())
+			}
+
+			elements := []object.Object{}
+			for iter := iterable.Iter(); ; {
+				if err := vm.budget.Step(); err != nil {
+					return err
+				}
+
+				_, value, ok := iter.Next()
+				if !ok {
+					break
+				}
+				elements = append(elements, value)
+			}
+
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+		case code.OpConcat:
+			numArrays := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := []object.Object{}
+			for _, array := range vm.stack[vm.sp-numArrays : vm.sp] {
+				elements = append(elements, array.(*object.Array).Elements...)
+			}
+			vm.sp = vm.sp - numArrays
+
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// A top-level return ends the program; the value stays just
+			// above the stack pointer for LastPoppedStackElem.
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+		case code.OpGetIter:
+			obj := vm.pop()
+
+			iterable, ok := obj.(object.Iterable)
+			if !ok {
+				return newError("not iterable: %s", obj.Type())
+			}
+
+			if err := vm.push(iterable.Iter()); err != nil {
+				return err
+			}
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			// The iterator stays on the stack for the whole loop and is
+			// only popped once it is exhausted.
+			iter := vm.stack[vm.sp-1].(*object.Iterator)
+
+			key, value, ok := iter.Next()
+			if !ok {
+				vm.pop()
+				vm.currentFrame().ip = pos - 1
+				continue
+			}
+
+			if err := vm.push(key); err != nil {
+				return err
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return newError("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+
+	return o
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return newError("stack overflow")
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	vm.enterUnit(f.cl.Unit)
+
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	vm.enterUnit(vm.currentFrame().cl.Unit)
+
+	return vm.frames[vm.framesIndex]
+}
+
+// enterUnit switches to the constants and globals of unit, so that a
+// closure imported from a module runs against its module's.
+func (vm *VM) enterUnit(unit *object.Unit) {
+	if unit != nil {
+		vm.constants, vm.globals = unit.Constants, unit.Globals
+	}
+}
+
+func (vm *VM) executeInfixOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	switch {
+	case object.IsNumber(left) && object.IsNumber(right):
+		result := object.NumberInfix(infixOperators[op], left, right)
+		if err, ok := result.(*object.Error); ok {
+			return err
+		}
+		return vm.push(result)
+	case left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ:
+		return vm.executeBooleanInfixOperation(op, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return vm.executeStringInfixOperation(op, left, right)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), infixOperators[op], right.Type())
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), infixOperators[op], right.Type())
+	}
+}
+
+func (vm *VM) executeBooleanInfixOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.Boolean).Value
+	rightValue := right.(*object.Boolean).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), infixOperators[op], right.Type())
+	}
+}
+
+func (vm *VM) executeStringInfixOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.String{Value: leftValue + rightValue})
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	case code.OpGreaterEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), infixOperators[op], right.Type())
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	switch operand := vm.pop().(type) {
+	case *object.Integer:
+		if operand.Value == math.MinInt64 {
+			return vm.push(object.NewInteger(new(big.Int).Neg(big.NewInt(operand.Value))))
+		}
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.BigInt:
+		return vm.push(object.NewInteger(new(big.Int).Neg(operand.Value)))
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
+		return newError("unknown operator: -%s", operand.Type())
+	}
+}
+
+func (vm *VM) executeBitNotOperator() error {
+	switch operand := vm.pop().(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: ^operand.Value})
+	case *object.BigInt:
+		return vm.push(object.NewInteger(new(big.Int).Not(operand.Value)))
+	default:
+		return newError("unknown operator: ~%s", operand.Type())
+	}
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, newError("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeStringIndex(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		export := left.(*object.Module).Export(index.(*object.String).Value)
+		if err, ok := export.(*object.Error); ok {
+			return err
+		}
+
+		return vm.push(export)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeSetIndex(left, index, value object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		max := int64(len(arrayObject.Elements) - 1)
+
+		if idx < 0 {
+			idx = max + 1 + idx
+		}
+
+		if idx < 0 || idx > max {
+			return newError("index out of range: %d", index.(*object.Integer).Value)
+		}
+
+		arrayObject.Elements[idx] = value
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		left.(*object.Hash).Set(key.HashKey(), object.HashPair{Key: index, Value: value})
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	idx := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if idx < 0 {
+		idx = max + 1 + idx
+	}
+
+	if idx < 0 || idx > max {
+		return vm.push(Null)
+	}
+
+	return vm.push(arrayObject.Elements[idx])
+}
+
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	max := int64(len(runes) - 1)
+
+	if idx < 0 {
+		idx = max + 1 + idx
+	}
+
+	if idx < 0 || idx > max {
+		return vm.push(Null)
+	}
+
+	return vm.push(&object.String{Value: string(runes[idx])})
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Get(key.HashKey())
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(pair.Value)
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return newError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	fn := cl.Fn
+
+	if min, max := fn.Arity(); numArgs < min || max >= 0 && numArgs > max {
+		return object.ArityError(fn.Name, min, max, numArgs)
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	frame.numArgs = numArgs
+	if frame.basePointer+fn.NumLocals >= StackSize {
+		return newError("stack overflow")
+	}
+
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+
+	// Arguments past the named parameters are collected for the rest
+	// parameter, whose slot follows them.
+	filled := frame.basePointer + numArgs
+	var rest *object.Array
+	if fn.Variadic {
+		rest = &object.Array{Elements: []object.Object{}}
+
+		if numArgs > fn.NumParameters {
+			filled = frame.basePointer + fn.NumParameters
+			rest.Elements = append(rest.Elements, vm.stack[filled:vm.sp]...)
+		}
+	}
+
+	// Parameters without an argument start out as null, as in the
+	// evaluator. Clearing the other locals also keeps a cell left behind
+	// by an earlier call from being mistaken for one of this frame's.
+	for i := filled; i < frame.basePointer+fn.NumLocals; i++ {
+		vm.stack[i] = Null
+	}
+
+	if rest != nil {
+		vm.stack[frame.basePointer+fn.NumParameters] = rest
+	}
+
+	vm.sp = frame.basePointer + fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(caller{vm}, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	// The evaluator aborts on any error value, including those produced by
+	// builtins, so the VM does too.
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+
+	if result != nil {
+		return vm.push(result)
+	}
+
+	return vm.push(Null)
+}
+
+// caller is the object.CallContext builtins get from the VM.
+type caller struct {
+	vm *VM
+}
+
+func (c caller) Call(fn object.Object, args ...object.Object) object.Object {
+	result, err := c.vm.Call(fn, args...)
+	if err != nil {
+		if errObj, ok := err.(*object.Error); ok {
+			return errObj
+		}
+
+		return &object.Error{Message: err.Error()}
+	}
+
+	return result
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free, Unit: vm.currentFrame().cl.Unit}
+	return vm.push(closure)
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+
+	return False
+}
+
+// deref returns the value held by a captured variable's cell, or obj
+// itself when it is not a cell.
+func deref(obj object.Object) object.Object {
+	if cell, ok := obj.(*object.Cell); ok {
+		return cell.Value
+	}
+
+	return obj
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,907 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"gomonkey/ast"
+	"gomonkey/compiler"
+	"gomonkey/evaluator"
+	"gomonkey/lexer"
+	"gomonkey/object"
+	"gomonkey/parser"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type vmTestCase struct {
+	expected interface{}
+	input    string
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{1, "1"},
+		{3, "1 + 2"},
+		{-1, "1 - 2"},
+		{2, "1 * 2"},
+		{2, "4 / 2"},
+		{65, "5 * (2 + 10) - -5"},
+		{50, "(5 + 10 * 2 + 15 / 3) * 2 + -10"},
+		{-1, "-7 % 3"},
+		{512, "2 ** 3 ** 2"},
+		{-4, "-2 ** 2"},
+		{255, "0xF0 | 0x0F"},
+		{6, "0b1100 ^ 0b1010"},
+		{-6, "~5"},
+		{1024, "1 << 10"},
+		{-4, "-16 >> 2"},
+		{1000000, "1_000_000"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"0.5", "0.5"},
+		{"1.5", "1 + 0.5"},
+		{"6.0", "2.0 * 3"},
+		{"0.25", "1 / 4.0"},
+		{"-1.5", "-1.5"},
+		{true, "1 == 1.0"},
+		{false, "0.5 > 1"},
+		{3, "floor(2.5) + ceil(0.1)"},
+		{"1.5", "7.5 % 2"},
+		{"0.25", "2 ** -2"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBigIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"9223372036854775808", "9223372036854775807 + 1"},
+		{"-9223372036854775809", "-9223372036854775808 - 1"},
+		{"18446744073709551616", "4294967296 * 4294967296"},
+		{9223372036854775807, "9223372036854775807 + 1 - 1"},
+		{"15511210043330985984000000", "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)"},
+		{true, "99999999999999999999 > 1"},
+		{"18446744073709551616", "2 ** 64"},
+		{"1", "99999999999999999999 % 7"},
+		{"9223372036854775808", "1 << 63"},
+		{"-100000000000000000000", "~99999999999999999999"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{true, "true"},
+		{true, "1 < 2"},
+		{false, "1 > 2"},
+		{true, "1 != 2"},
+		{true, "true == true"},
+		{false, "true != true"},
+		{true, "(1 < 2) == true"},
+		{false, "!5"},
+		{true, "!(if (false) { 5; })"},
+		{false, `"a" == "b"`},
+		{true, "1 <= 1"},
+		{false, "1 >= 2"},
+		{true, `"apple" < "banana"`},
+		{true, `"b" >= "a"`},
+		{false, "true && false"},
+		{true, "false || true"},
+		{true, "1 < 2 && 2 < 3"},
+		{false, "false && 1 + true"},
+		{true, "true || -true"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{10, "if (true) { 10 }"},
+		{20, "if (false) { 10 } else { 20 }"},
+		{10, "if (1) { 10 }"},
+		{Null, "if (1 > 2) { 10 }"},
+		{20, "if ((if (false) { 10 })) { 10 } else { 20 }"},
+		{20, `if ("a" == "b") { 10 } else { 20 }`},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestWhileLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{5, "let i = 0; while (true) { i = i + 1; if (i > 4) { break; } }; i"},
+		{5, "let i = 0; let odd = 0; while (i < 10) { i = i + 1; if (i / 2 * 2 == i) { continue; } odd = odd + 1; }; odd"},
+		{14, "let f = fn() { let i = 0; while (true) { i = i + 1; if (i == 7) { return i * 2; } } }; f()"},
+		{Null, "while (false) { 1 }"},
+		{3, "let i = 0; while (i < 3) { let j = 0; while (true) { j = j + 1; if (j > 10) { break; } } i = i + 1; }; i"},
+		{100000, "let i = 0; while (i < 100000) { i = i + 1; }; i"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForInLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{6, "let sum = 0; for (x in [1, 2, 3]) { sum = sum + x; }; sum"},
+		{80, "let sum = 0; for (i, x in [10, 20, 30]) { sum = sum + i * x; }; sum"},
+		{"olléh", `let out = ""; for (ch in "héllo") { out = ch + out; }; out`},
+		{3, `let sum = 0; for (k, v in {"a": 1, "b": 2}) { sum = sum + v; }; sum`},
+		{[]int{1, 2}, `let seen = []; for (v in {"a": 1, "b": 2}) { seen = push(seen, v) }; seen`},
+		{22, "let sum = 0; for (x in range(10, 0, -3)) { sum = sum + x; }; sum"},
+		{3, "let n = 0; for (x in range(1000000000000)) { if (x == 3) { break; } n = n + 1; }; n"},
+		{5, "let n = 0; for (x in range(10)) { if (x / 2 * 2 == x) { continue; } n = n + 1; }; n"},
+		{5, "let f = fn(arr) { for (x in arr) { if (x > 1) { return x; } } }; f([1, 5, 7])"},
+		{6, "let f = fn(arr) { let sum = 0; for (i, x in arr) { sum = sum + x; } sum }; f([1, 2, 3])"},
+		{Null, "for (x in []) { x }"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoopsLeaveStackBalanced(t *testing.T) {
+	inputs := []string{
+		"for (x in range(100)) { if (x == 1) { break; } }",
+		"for (x in range(100)) { continue; }",
+		"while (false) { }",
+	}
+
+	for _, input := range inputs {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := comp.Bytecode()
+		vm := New(bytecode)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if vm.sp != bytecode.NumLocals {
+			t.Errorf("stack not balanced after %q. sp=%d", input, vm.sp)
+		}
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []vmTestCase{
+		{2, "let x = 1; x = 2; x"},
+		{10, "let x = 1; let y = 2; x = y = 5; x + y"},
+		{6, "let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x"},
+		{"ab", `let s = "a"; s += "b"; s`},
+		{6, "let f = fn() { let i = 0; let sum = 0; while (i < 4) { sum += i; i += 1 }; sum }; f()"},
+		{3, "let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next(); next()"},
+		{5, "let x = 1; let f = fn() { x = 5 }; f(); x"},
+		{11, "let f = fn() { let c = 1; let inc = fn() { c = c + 10 }; inc(); c }; f()"},
+		{3, "let f = fn(a) { let g = fn() { fn() { a += 1 } }; let h = g(); h(); h(); a }; f(1)"},
+		{13, "let mk = fn() { let c = 0; fn() { c += 1 } }; let a = mk(); let b = mk(); a(); a(); b() * 10 + a()"},
+		{18, "let a = [1, 2, 3]; a[0] = 10; a[-1] += 5; a[0] + a[2]"},
+		{7, "let a = [1, 2]; let b = a; b[1] = 7; a[1]"},
+		{7, `let h = {"a": 1}; h["a"] += 1; h["b"] = 5; h["a"] + h["b"]`},
+		{9, "let a = [[1], [2]]; a[1][0] = 9; a[1][0]"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBlockScoping(t *testing.T) {
+	tests := []vmTestCase{
+		{1, "let x = 1; if (true) { let x = 2; }; x"},
+		{2, "let x = 1; if (true) { let x = 2; x }"},
+		{2, "let x = 1; if (true) { x = 2; }; x"},
+		{2, "let x = 1; if (true) { let x = x + 1; x }"},
+		{2, "let x = 1; if (true) { let x = 2; if (true) { let x = 3; } x }"},
+		{1, "let f = fn() { let x = 1; if (true) { let x = 2; }; x }; f()"},
+		{2, "if (true) { let x = 1; let g = fn() { x }; let x = 2; g() }"},
+		{1, "let x = 1; if (true) { let g = fn() { x }; let x = 2; g() }"},
+		{[]int{1, 2}, "let x = 1; if (true) { let g = fn() { x }; let x = 2; [g(), x] }"},
+		{[]int{0, 2, 4}, "let i = 0; let seen = []; while (i < 3) { let v = i * 2; seen = push(seen, v); i += 1 }; seen"},
+		{10, "let x = 10; for (x in [1, 2]) { }; x"},
+		{[]int{0, 1, 2}, "let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }) }; [fs[0](), fs[1](), fs[2]()]"},
+		{[]int{0, 1}, "let f = fn() { let fs = []; for (i in range(2)) { fs = push(fs, fn() { i }) }; fs }; let fs = f(); [fs[0](), fs[1]()]"},
+		{[]int{0, 2}, "let fs = []; let i = 0; while (i < 3) { let j = i; fs = push(fs, fn() { j }); i += 1 }; [fs[0](), fs[2]()]"},
+		{[]int{1, 2}, "let fs = []; for (i in [1, 2]) { fn get() { i } fs = push(fs, get) }; [fs[0](), fs[1]()]"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{1, "let one = 1; one"},
+		{3, "let one = 1; let two = 2; one + two"},
+		{2, "let one = 1; let one = one + 1; one"},
+		{5, "let five = 5;"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCollections(t *testing.T) {
+	tests := []vmTestCase{
+		{"monkey", `"mon" + "key"`},
+		{[]int{1, 2, 3}, "[1, 1 + 1, 3]"},
+		{"{1: 2, two: 4}", `{1: 2, "two": 2 * 2}`},
+		{2, "[1, 2, 3][1]"},
+		{3, "[1, 2, 3][-1]"},
+		{Null, "[1, 2, 3][3]"},
+		{1, "{1: 1, 2: 2}[1]"},
+		{Null, "{}[0]"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{15, "let f = fn() { 5 + 10; }; f();"},
+		{3, "let one = fn() { 1; }; let two = fn() { 2; }; one() + two()"},
+		{99, "let earlyExit = fn() { return 99; 100; }; earlyExit();"},
+		{Null, "let noReturn = fn() { }; noReturn();"},
+		{4, "let sum = fn(a, b) { let c = a + b; c; }; sum(1, 3);"},
+		{10, "let globalNum = 10; let f = fn() { let num = 1; globalNum - num + 1 }; f()"},
+		{1, "let identity = fn(x) { x }; identity(identity)(1)"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{99, "let newClosure = fn(a) { fn() { a; }; }; let closure = newClosure(99); closure();"},
+		{14, `
+		let newAdderOuter = fn(a, b) {
+			let c = a + b;
+			fn(d) {
+				let e = d + c;
+				fn(f) { e + f; };
+			};
+		};
+		let newAdderInner = newAdderOuter(1, 2)
+		let adder = newAdderInner(3);
+		adder(8);`},
+		{610, `
+		let fibonacci = fn(x) {
+			if (x == 0) { return 0; }
+			if (x == 1) { return 1; }
+			fibonacci(x - 1) + fibonacci(x - 2);
+		};
+		fibonacci(15);`},
+		{0, `
+		let wrapper = fn() {
+			let countDown = fn(x) {
+				if (x == 0) { return 0; }
+				countDown(x - 1);
+			};
+			countDown(1);
+		};
+		wrapper();`},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{11, "let f = fn(a, b = 10) { a + b }; f(1)"},
+		{3, "let f = fn(a, b = 10) { a + b }; f(1, 2)"},
+		{[]int{5, 10}, "let f = fn(a = 1, b = a * 2) { [a, b] }; f(5)"},
+		{2, "let n = 1; let f = fn(x = n) { x }; n = 2; f()"},
+		{12, "let outer = fn(k) { fn(x = k * 2) { x } }; outer(6)()"},
+		{[]int{}, "let f = fn(...rest) { rest }; f()"},
+		{[]int{2, 3}, "let f = fn(a, ...rest) { rest }; f(1, 2, 3)"},
+		{[]int{1, 2, 0}, "let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1)"},
+		{6, "let f = fn(...xs) { let n = 0; for (x in xs) { n += x }; n }; f(1, 2, 3)"},
+		{3, "let f = fn(a, ...rest) { let g = fn() { len(rest) + a }; g() }; f(1, 1, 1)"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{6, "let add = fn(a, b, c) { a + b + c }; add(...[1, 2, 3])"},
+		{6, "let add = fn(a, b, c) { a + b + c }; add(1, ...[2], ...[3])"},
+		{[]int{1, 2, 3}, "let f = fn(...xs) { xs }; f(...[], 1, ...[2, 3])"},
+		{[]int{1, 2, 3, 4}, "let xs = [2, 3]; [1, ...xs, 4]"},
+		{[]int{}, "[...[], ...[]]"},
+		{[]int{0, 1, 2}, "[...range(3)]"},
+		{2, "len(...[[1, 2]])"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	tests := []vmTestCase{
+		{3, "fn add(a, b) { a + b } add(1, 2)"},
+		{3, "let r = add(1, 2); fn add(a, b) { a + b }; r"},
+		{120, "fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } } fact(5)"},
+		{[]bool{true, true, false}, `
+		let r = [isEven(10), isOdd(7), isEven(3)];
+		fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+		fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+		r`},
+		{true, `
+		let outer = fn(n) {
+			let r = even(n);
+			fn even(n) { if (n == 0) { true } else { odd(n - 1) } }
+			fn odd(n) { if (n == 0) { false } else { even(n - 1) } }
+			r
+		};
+		outer(4)`},
+		{2, "let x = 1; fn get() { x } x = 2; get()"},
+		{3, "fn get() { y } let y = 3; get()"},
+		{Null, "let r = get(); fn get() { y } let y = 3; r"},
+		{5, "let f = fn() { fn get() { z } let z = 5; get() }; f()"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{4, `len("four")`},
+		{2, `len({1: 2, 3: 4})`},
+		{1, `first([1, 2, 3])`},
+		{Null, `first([])`},
+		{[]int{2, 3}, `rest([1, 2, 3])`},
+		{[]int{1}, `push([], 1)`},
+		{true, `has({"a": 1}, "a")`},
+		{20, `if (has({}, "a")) { 10 } else { 20 }`},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{[]int{2, 4, 6}, "map([1, 2, 3], fn(x) { x * 2 })"},
+		{[]int{11, 12}, "let k = 10; let f = fn() { map([1, 2], fn(x) { x + k }) }; f()"},
+		{[]int{1, 2, 3}, "sort([3, 1, 2])"},
+		{[]int{3, 2, 1}, "sort([1, 3, 2], fn(a, b) { a > b })"},
+		{6, "let sum = fn(xs) { let t = 0; for (x in map(xs, fn(x) { x })) { t += x }; t }; sum([1, 2, 3])"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallFromHost(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse("let k = 2; let scale = fn(x) { x * k }; let fail = fn() { 1 + true };")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := comp.Bytecode()
+	globals := make([]object.Object, GlobalsSize)
+	machine := NewWithGlobalsStore(bytecode, globals)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	scale := globals[1]
+
+	for i := 1; i <= 3; i++ {
+		result, err := machine.Call(scale, &object.Integer{Value: int64(i)})
+		if err != nil {
+			t.Fatalf("call failed: %s", err)
+		}
+
+		testExpectedObject(t, "scale", 2*i, result)
+	}
+
+	result, err := machine.Call(object.GetBuiltinByName("len"), &object.String{Value: "abc"})
+	if err != nil {
+		t.Fatalf("call failed: %s", err)
+	}
+	testExpectedObject(t, "len", 3, result)
+
+	if _, err := machine.Call(globals[2]); err == nil || err.Error() != "1:59: type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+
+	if _, err := machine.Call(scale); err == nil {
+		t.Errorf("expected an arity error")
+	}
+
+	result, err = machine.Call(scale, &object.Integer{Value: 5})
+	if err != nil {
+		t.Fatalf("call after an error failed: %s", err)
+	}
+	testExpectedObject(t, "scale", 10, result)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"true < 1", "type mismatch: BOOLEAN < INTEGER"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`"Hello" - "World!"`, "unknown operator: STRING - STRING"},
+		{"[1] == [1]", "unknown operator: ARRAY == ARRAY"},
+		{"1[0]", "index operator not supported: INTEGER"},
+		{"{}[[]]", "unusable as hash key: ARRAY"},
+		{"{fn() {}: 1}", "unusable as hash key: FUNCTION"},
+		{"1()", "not a function: INTEGER"},
+		{"fn(a) { a }()", "wrong number of arguments: want=1, got=0"},
+		{"let add = fn(a, b) { a + b }; add(1)", "wrong number of arguments to add: want=2, got=1"},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3)", "wrong number of arguments to add: want=2, got=3"},
+		{"len(1); 5", "`len` builtin function doesn't support argument of type INTEGER"},
+		{"let f = fn() { f() }; f()", "stack overflow"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1"},
+		{"1 / 0", "division by zero"},
+		{"1 << -1", "negative shift count: -1"},
+		{"1 << 100000000000", "shift result too large: more than 1048576 bits"},
+		{"99999999999999999999 << 1048560", "shift result too large: more than 1048576 bits"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"let f = fn(n) { 10 % n }; f(0)", "modulo by zero"},
+		{"99999999999999999999 / 0", "division by zero"},
+		{`let s = "ab"; s[0] = "c"`, "index assignment not supported: STRING"},
+		{"let f = fn(a, b = 2) { a }; f(1, 2, 3)", "wrong number of arguments to f: want=1 to 2, got=3"},
+		{"let f = fn(a, ...rest) { a }; f()", "wrong number of arguments to f: want=at least 1, got=0"},
+		{"let f = fn(a, b) { a }; f(...[1, 2, 3])", "wrong number of arguments to f: want=2, got=3"},
+		{"[...5]", "not iterable: INTEGER"},
+		{"fn f(a) { a } f()", "wrong number of arguments to f: want=1, got=0"},
+	}
+
+	for _, tt := range tests {
+		_, err := runVM(t, tt.input)
+		if err == nil {
+			t.Errorf("expected VM error for %q but resulted in none", tt.input)
+			continue
+		}
+
+		var errObj *object.Error
+		if !errors.As(err, &errObj) {
+			t.Errorf("VM error is not *object.Error. got=%T", err)
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong VM error for %q: want=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tests := []struct {
+		input    string
+		budget   *object.Budget
+		expected string
+	}{
+		{"while (true) { }", object.NewBudget(context.Background(), 1000, 0), "step limit of 1000 exceeded"},
+		{"len([...range(30000000)])", object.NewBudget(context.Background(), 100, 0), "step limit of 100 exceeded"},
+		{"puts(...range(30000000))", object.NewBudget(context.Background(), 100, 0), "step limit of 100 exceeded"},
+		{"len([...range(1000000000000)])", object.NewBudget(timeout, 0, 0), "evaluation stopped: context deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		vm.SetBudget(tt.budget)
+
+		var errObj *object.Error
+		if err := vm.Run(); !errors.As(err, &errObj) {
+			t.Errorf("expected a limit error for %q. got=%v", tt.input, err)
+			continue
+		}
+
+		if errObj.Kind != object.LimitError || errObj.Message != tt.expected {
+			t.Errorf("wrong error for %q: want=%q, got=%q (kind %v)", tt.input, tt.expected, errObj.Message, errObj.Kind)
+		}
+	}
+}
+
+func TestRuntimeErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true", "1:1: type mismatch: INTEGER + BOOLEAN"},
+		{"let a = 1;\nlet f = fn() {\n  a + -true\n};\nf()", "3:7: unknown operator: -BOOLEAN"},
+		{"let x = 1;\nlen(x)", "2:1: `len` builtin function doesn't support argument of type INTEGER"},
+		{"[1,\n  foo]", "2:3: identifier not found: foo"},
+	}
+
+	for _, tt := range tests {
+		_, err := runVM(t, tt.input)
+		if err == nil {
+			t.Errorf("expected VM error for %q but resulted in none", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error for %q: want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+// TestEngineParity runs the same programs through evaluator.Eval and the VM
+// and expects identical results, including error messages.
+func TestEngineParity(t *testing.T) {
+	inputs := []string{
+		"5",
+		"(5 + 10 * 2 + 15 / 3) * 2 + -10",
+		"!!5",
+		"1 < 2 == true",
+		`"Hello" != "Hello"`,
+		"if (1 > 2) { 10 }",
+		"if (1 > 2) { 10 } else { 20 }",
+		"return 2 * 5; 9;",
+		"9; return 2 * 5; 9;",
+		"if (10 > 1) { if (10 > 1) { return 10; } return 1; }",
+		"5 + true; 5;",
+		"-true",
+		"5; true + false; 5",
+		"if (10 > 1) { true + false; }",
+		`"Hello" - "World!";`,
+		"foobar",
+		"let a = 5; let b = a; let c = a + b + 5; c;",
+		"let a = 5;",
+		"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));",
+		"fn(x) { x; }(5)",
+		"fn() { let a = 1; }()",
+		"let newAdder = fn(x) { fn(y) { x + y } }; newAdder(2)(3)",
+		`"Hello" + " " + "World!"`,
+		`len("hello world")`,
+		`len(1)`,
+		`len("one", "two")`,
+		`first([])`,
+		`rest([1, 2, 3])`,
+		`push([1, 2], 3)`,
+		`exit(0, 1)`,
+		"[1, 2 * 2, 3 + 3]",
+		"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+		"[1, 2, 3][-4]",
+		`let two = "two"; {"one": 10 - 9, two: 1 + 1, "thr" + "ee": 6 / 2, 4: 4, true: 5}`,
+		`{"foo": 5}["bar"]`,
+		`{"name": "Monkey"}[fn(x) { x }];`,
+		`keys({"a": 1, 2: "b"})`,
+		`put({"a": 1}, "b", 2)`,
+		`delete({"a": 1, "b": 2}, "a")`,
+		"true == 1",
+		"1 < true",
+		"[1] + [2]",
+		"5(1)",
+		"1 + 0.5 * 2",
+		"1e3 / 8",
+		"-0.5 < 0",
+		"2.5 + true",
+		"round(2.5) + float(1)",
+		"9223372036854775807 * 3",
+		"-(-9223372036854775808)",
+		"123456789012345678901234567890 / 10",
+		`99999999999999999999 + "a"`,
+		`len("héllo") + bytes_len("héllo")`,
+		`"🙈🙉🙊"[1]`,
+		`"abc"[-4]`,
+		`slice("héllo", 1, 3)`,
+		`slice([1, 2, 3], 1)`,
+		"let i = 0; while (i < 5) { i = i + 1; }",
+		"let i = 0; while (i < 5) { i = i + 1; }; i",
+		"let i = 0; while (true) { if (i > 2) { break; } i = i + 1; }; i",
+		"while (1 + true) { }",
+		"let n = 0; for (x in range(3, 9, 2)) { n = n + x; }; n",
+		`let s = ""; for (k, v in {"a": 1, "b": 2}) { s = s + k; }; s`,
+		`let seen = []; for (v in {"a": 1, "b": 2}) { seen = push(seen, v) }; seen`,
+		"for (x in 5) { x }",
+		"for (x in [1, 2]) { x }",
+		"range(1, 2)",
+		"let x = 1; x = x + 1",
+		"let x = 1; x *= 2.5; x",
+		"let x = 9223372036854775807; x += 1; x",
+		"let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next()",
+		"let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }); }; fs[0]() + fs[2]()",
+		"let a = [1, 2]; a[-1] = 5; a",
+		`let h = {}; h["k"] = 1; h["k"] += 1; h`,
+		"let a = [1]; a[5] = 2",
+		"let a = 1; a[0] = 2",
+		"let x = 1; x += true",
+		"7 % 3 + -7 % 3 + 7 % -3",
+		"2 ** 3 ** 2 - -2 ** 2",
+		"2 ** -1",
+		"2 ** 100 % 1000007",
+		"(-3) ** 41",
+		"1.5 % 1",
+		"1 / 0",
+		"5 % 0",
+		"1.0 / 0",
+		"99999999999999999999 % 0",
+		`"a" < "b"`,
+		`"a" >= "b"`,
+		`"a" ** "b"`,
+		"true >= false",
+		"[1 <= 2, 2 <= 1, 1.5 >= 1, 99999999999999999999 >= 1]",
+		"0xF0 | 0x0F & 0b1010 ^ 0o7",
+		"[~0, ~5, 1 << 62, 1 << 63, 1 << 64, -1 << 63, -1 << 64]",
+		"[1 >> 100, -1 >> 100, -16 >> 2, (1 << 100) >> 99]",
+		"(1 << 70) & ((1 << 71) - 1) | 5 ^ 3",
+		"1 << -1",
+		"1 >> 99999999999999999999",
+		"1.5 & 1",
+		"~true",
+		"~1.5",
+		"1_000_000 + 0b1",
+		"let add = fn(a, b) { a + b }; add(1)",
+		"let f = fn() { let g = fn(x) { x }; g(1, 2) }; f()",
+		"fn() { 1 }(1)",
+		"1 && 2",
+		"0 || 2",
+		"if (false) { 1 } && 3",
+		"if (false) { 1 } || 3",
+		`"" && 5`,
+		"true && 1 + true",
+		"let n = 0; let f = fn() { n += 1; true }; false && f(); true || f(); true && f(); false || f(); n",
+		"let f = fn(x) { x > 0 && x < 10 || x == 42 }; [f(5), f(42), f(-1)]",
+		"let f = fn(a, b = a + 1, ...rest) { [a, b, rest] }; [f(1), f(1, 5), f(1, 5, 6, 7)]",
+		"let f = fn(a, b = 2) { a }; f()",
+		"let f = fn(...rest) { rest }; f(...5)",
+		`let xs = [2, 3]; [1, ...xs, ..."ab", ...{"k": 1}]`,
+		"let f = fn(a, b) { a - b }; f(...[9], ...[4])",
+		"puts(...[])",
+		"let r = fib(10); fn fib(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } } r",
+		"let f = fn() { let r = g(); fn g() { h() } fn h() { 42 } r }; f()",
+		"fn f(a) { a } f(1, 2)",
+		"let x = 1; if (false) { } else { let x = 2; }; x",
+		"let x = 1; if (true) { let x = 2; if (true) { x = 3; } x }",
+		"let x = 1; if (true) { let g = fn() { x }; let x = 2; g() }",
+		"let x = 1; if (true) { let x = 2; fn g() { x } g() }",
+		"let x = 1; let fs = []; for (i in [1, 2]) { fs = push(fs, fn() { x }); let x = i; }; [fs[0](), fs[1]()]",
+		"if (true) { let y = 1; }; y",
+		"if (true) { fn f() { 1 } }; f()",
+		"for (x in [1]) { }; x",
+		"let i = 0; while (i < 2) { i += 1; let i = 10; }; i",
+		"let n = 0; for (x in [1, 2]) { let n = x; }; n",
+		"let fs = []; for (i in range(3)) { fs = push(fs, fn() { i }) }; [fs[0](), fs[1](), fs[2]()]",
+		"let fs = []; let i = 0; while (i < 3) { let j = i; fs = push(fs, fn() { j }); i += 1 }; [fs[0](), fs[2]()]",
+		"let f = fn(n) { let total = 0; for (i in range(n)) { let sq = i * i; total += sq; }; total }; f(4)",
+		"map([1, 2, 3], fn(x) { x * 2 })",
+		"let k = 10; map([1, 2], fn(x) { x + k })",
+		"map([1, 2], len)",
+		"map([1, 2], fn(x) { x + true })",
+		"let x = 1; let g = fn() { x }; let x = 2; g()",
+		"let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()",
+		"let x = 1; let f = fn() { let g = fn() { x }; let x = 2; g() }; f()",
+		"let x = 1; let f = fn() { let g = fn() { x }; let a = g(); let x = 2; [a, g(), x] }; f()",
+		"let x = 1; let f = fn() { let x = 2; fn g() { x } g() }; f()",
+		"let f = fn(x) { let g = fn() { x }; let x = 5; g() }; f(2)",
+		"sort([3, 1.5, 2])",
+		`sort(["b", "c", "a"])`,
+		"sort([3, 1, 2], fn(a, b) { a > b })",
+		`sort([1, "a"])`,
+		"sort([2, 1], fn(a, b) { a + true })",
+		"map([[3, 1], [2, 0]], fn(pair) { sort(pair, fn(a, b) { a < b }) })",
+		"if (true) { }",
+		"if (false) { 1 } else { }",
+		"[if (true) { }, if (false) { 1 } else { }, 2]",
+		"let f = fn() { if (true) { } }; f()",
+		"fn() { }()",
+		"let x = fn() { }(); x == 1",
+		"let i = 0; while (true) { i += 1; if (i > 2) { break } }; i",
+		"let a = [1]; a[0] = a; a",
+		`let h = {"a": 1}; h["self"] = h; h["list"] = [h]; h`,
+		"let f = fn() { f = 1; 2 }; f()",
+		"let f = fn() { f = 1; 2 }; [f(), f]",
+		"let f = fn() { let g = fn() { f = 10 }; g(); 2 }; [f(), f]",
+		"let f = fn(n) { if (n == 0) { f = 5; return 0; }; f(n - 1) + 1 }; [f(3), f]",
+		"let g = fn() { let f = fn() { f = 1; 2 }; [f(), f] }; g()",
+		"if (true) { let f = fn() { f = 1; 2 }; [f(), f] }",
+		"let r = []; for (i in range(2)) { let f = fn() { f = i; 0 }; f(); r = push(r, f) }; r",
+		"if (true) { fn f() { f = 3; 4 } [f(), f] }",
+		"fn(x) { x }",
+		"let f = fn(x) { x * 2 }; f",
+		"fn g(a, b = 2, ...c) { a } g",
+		"let adder = fn(x) { fn(y) { x + y } }; [adder(1), puts]",
+		`let h = {"f": fn() { 1 }}; h`,
+	}
+
+	for _, input := range inputs {
+		expected := evaluator.Eval(parse(input), object.NewEnvironment())
+
+		actual, err := runVM(t, input)
+		if err != nil {
+			var errObj *object.Error
+			if !errors.As(err, &errObj) {
+				errObj = &object.Error{Message: err.Error()}
+			}
+			actual = errObj
+		}
+
+		if expected.Type() != actual.Type() || expected.Inspect() != actual.Inspect() {
+			t.Errorf("engines disagree on %q.\neval=%s (%s)\nvm  =%s (%s)",
+				input, expected.Inspect(), expected.Type(), actual.Inspect(), actual.Type())
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	return p.ParseProgram()
+}
+
+func runVM(t *testing.T, input string) (object.Object, error) {
+	t.Helper()
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		return nil, err
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		return nil, err
+	}
+
+	return vm.LastPoppedStackElem(), nil
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		result, err := runVM(t, tt.input)
+		if err != nil {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		testExpectedObject(t, tt.input, tt.expected, result)
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		integer, ok := actual.(*object.Integer)
+		if !ok || integer.Value != int64(expected) {
+			t.Errorf("%q: object is not Integer %d. got=%T (%+v)", input, expected, actual, actual)
+		}
+	case bool:
+		boolean, ok := actual.(*object.Boolean)
+		if !ok || boolean.Value != expected {
+			t.Errorf("%q: object is not Boolean %t. got=%T (%+v)", input, expected, actual, actual)
+		}
+	case string:
+		if actual == nil || actual.Inspect() != expected {
+			t.Errorf("%q: object does not inspect as %q. got=%T (%+v)", input, expected, actual, actual)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%q: object is not Array of %d. got=%T (%+v)", input, len(expected), actual, actual)
+			return
+		}
+
+		for i, el := range expected {
+			testExpectedObject(t, input, el, array.Elements[i])
+		}
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("%q: object is not Null. got=%T (%+v)", input, actual, actual)
+		}
+	}
+}
+
+var testModules = fstest.MapFS{
+	"math.mk":    {Data: []byte("let pi = 3; let _hidden = 1; fn square(x) { x * x }; let area = fn(r) { pi * square(r) };")},
+	"counter.mk": {Data: []byte("let count = 0; let next = fn() { count = count + 1; count };")},
+	"uses.mk":    {Data: []byte(`let m = import "math"; let double_area = fn(r) { 2 * m.area(r) };`)},
+	"cycle_a.mk": {Data: []byte(`let b = import "cycle_b";`)},
+	"cycle_b.mk": {Data: []byte(`let a = import "cycle_a";`)},
+	"broken.mk":  {Data: []byte("let x = 1;\nlet y = x + true;")},
+	"invalid.mk": {Data: []byte("let = 1;")},
+	"fail.mk":    {Data: []byte("let fail = fn() { 1 + true };")},
+}
+
+func TestModules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = import "math"; m.area(2)`, "12"},
+		{`let m = import "math"; m.square(3)`, "9"},
+		{`let m = import "math"; m["pi"]`, "3"},
+		{`import "math"`, "<module math.mk>"},
+		{`(import "uses").double_area(1)`, "6"},
+		{`let a = import "counter"; let b = import "./counter.mk"; a.next(); b.next()`, "2"},
+		{`let c = import "counter"; let before = c.count; c.next(); c.next(); [before, c.count]`, "[0, 2]"},
+		{`let pi = 100; let m = import "math"; m.area(1)`, "3"},
+		{`let f = fn() { let m = import "math"; map([1, 2], m.area) }; f()`, "[3, 12]"},
+		{`let m = import "math"; m._hidden`, "ERROR: 1:24: module math.mk has no export _hidden"},
+		{`import "cycle_a"`, "ERROR: cycle_b.mk:1:9: import cycle: cycle_a.mk -> cycle_b.mk -> cycle_a.mk"},
+		{`import "missing"`, `ERROR: 1:1: cannot find module "missing"`},
+		{`import "broken"`, "ERROR: broken.mk:2:9: type mismatch: INTEGER + BOOLEAN"},
+		{`import "invalid"`, "ERROR: invalid.mk:1:5: expected next token to be IDENT, got = instead"},
+		{`(import "fail").fail()`, "ERROR: fail.mk:1:19: type mismatch: INTEGER + BOOLEAN"},
+		{`let h = {"a": 1}; h.a`, "1"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetModules(object.NewModules(object.NewFSResolver(testModules), nil))
+		expected := evaluator.Eval(parse(tt.input), env)
+
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		machine := New(comp.Bytecode())
+		machine.SetModules(object.NewModules(object.NewFSResolver(testModules), nil))
+
+		var actual object.Object
+		if err := machine.Run(); err != nil {
+			actual = err.(*object.Error)
+		} else {
+			actual = machine.LastPoppedStackElem()
+		}
+
+		if actual.Inspect() != tt.expected {
+			t.Errorf("wrong vm result for %q. want=%q, got=%q", tt.input, tt.expected, actual.Inspect())
+		}
+
+		if expected.Inspect() != tt.expected {
+			t.Errorf("wrong eval result for %q. want=%q, got=%q", tt.input, tt.expected, expected.Inspect())
+		}
+	}
+}
+
+func TestCallingModuleFunctionsFromHost(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let k = 5; let m = import "math"; let f = import "fail";`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	globals := make([]object.Object, GlobalsSize)
+	machine := NewWithGlobalsStore(comp.Bytecode(), globals)
+	machine.SetModules(object.NewModules(object.NewFSResolver(testModules), nil))
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	area := globals[1].(*object.Module).Export("area")
+	fail := globals[2].(*object.Module).Export("fail")
+
+	result, err := machine.Call(area, &object.Integer{Value: 2})
+	if err != nil {
+		t.Fatalf("call failed: %s", err)
+	}
+	testExpectedObject(t, "area", 12, result)
+
+	if _, err := machine.Call(fail); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	// The failed call must leave the VM running against the program's
+	// own globals again.
+	if &machine.globals[0] != &globals[0] {
+		t.Errorf("vm still uses the module's globals after a failed call")
+	}
+
+	result, err = machine.Call(area, &object.Integer{Value: 1})
+	if err != nil {
+		t.Fatalf("call failed: %s", err)
+	}
+	testExpectedObject(t, "area", 3, result)
+}